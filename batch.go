@@ -0,0 +1,274 @@
+package gollum
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	oai "github.com/azr4e1/gollum/openai"
+)
+
+// BatchRequest is a request of a batch. CustomID identifies its result and
+// must be unique in the batch.
+type BatchRequest struct {
+	CustomID string
+	Request  CompletionRequest
+}
+
+// BatchResult is the outcome of a batch request: a response, or the error
+// it failed with.
+type BatchResult struct {
+	CustomID  string
+	RequestID string
+	Response  CompletionResponse
+	Err       error
+}
+
+// Batch is the state of a batch of requests processed asynchronously by
+// the provider.
+type Batch struct {
+	ID           string
+	Status       string
+	InputFileID  string
+	OutputFileID string
+	ErrorFileID  string
+	Total        int
+	Completed    int
+	Failed       int
+	Created      time.Time
+	Expires      time.Time
+	Metadata     map[string]string
+	// Errors are the validation errors of the input file.
+	Errors []string
+}
+
+// Done reports whether the batch reached a final status: completed,
+// failed, expired or cancelled.
+func (b Batch) Done() bool {
+	return oai.Batch{Status: b.Status}.Done()
+}
+
+func batchFromOpenAI(batch oai.Batch) Batch {
+	converted := Batch{
+		ID:           batch.Id,
+		Status:       batch.Status,
+		InputFileID:  batch.InputFileId,
+		OutputFileID: batch.OutputFileId,
+		ErrorFileID:  batch.ErrorFileId,
+		Total:        batch.RequestCounts.Total,
+		Completed:    batch.RequestCounts.Completed,
+		Failed:       batch.RequestCounts.Failed,
+		Metadata:     batch.Metadata,
+	}
+	if batch.CreatedAt != 0 {
+		converted.Created = time.Unix(batch.CreatedAt, 0)
+	}
+	if batch.ExpiresAt != 0 {
+		converted.Expires = time.Unix(batch.ExpiresAt, 0)
+	}
+	if batch.Errors != nil {
+		for _, e := range batch.Errors.Data {
+			message := fmt.Sprintf("%s: %s", e.Code, e.Message)
+			if e.Line != nil {
+				message = fmt.Sprintf("line %d: %s", *e.Line, message)
+			}
+			converted.Errors = append(converted.Errors, message)
+		}
+	}
+	return converted
+}
+
+func checkCustomIDs(requests []BatchRequest) error {
+	ids := map[string]bool{}
+	for _, r := range requests {
+		if r.CustomID == "" {
+			return errors.New("batch request without custom id.")
+		}
+		if ids[r.CustomID] {
+			return fmt.Errorf("duplicate batch custom id: %s.", r.CustomID)
+		}
+		ids[r.CustomID] = true
+	}
+	return nil
+}
+
+// WriteBatchJSONL writes requests as an OpenAI batch input file.
+func WriteBatchJSONL(w io.Writer, requests []BatchRequest) error {
+	err := checkCustomIDs(requests)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for _, r := range requests {
+		body := r.Request.ToOpenAI()
+		body.Stream = false
+		line := oai.BatchRequestLine{
+			CustomId: r.CustomID,
+			Method:   http.MethodPost,
+			URL:      oai.BatchCompletionURL,
+			Body:     body,
+		}
+		err := encoder.Encode(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadBatchResults reads an OpenAI batch output or error file into results
+// keyed by custom id. Requests that failed have a typed Err, like the
+// errors returned by Complete. When a custom id appears more than once, as
+// in the output of a resumed BatchComplete, the last line wins.
+func ReadBatchResults(r io.Reader) (map[string]BatchResult, error) {
+	results := map[string]BatchResult{}
+	scanner := bufio.NewScanner(r)
+	// a single reply can exceed the default 64KB
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		resLine := oai.BatchResponseLine{}
+		err := json.Unmarshal(text, &resLine)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		results[resLine.CustomId] = batchResultFromOpenAI(resLine)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func batchResultFromOpenAI(line oai.BatchResponseLine) BatchResult {
+	result := BatchResult{CustomID: line.CustomId}
+	if line.Response == nil {
+		e := oai.CompletionError{Message: "batch request failed without a response."}
+		if line.Error != nil {
+			e = *line.Error
+		}
+		result.Err = newAPIError(OPENAI.String(), 0, e.Code, e.Type, e.Message, nil, nil)
+		return result
+	}
+
+	body := line.Response.Body
+	body.StatusCode = line.Response.StatusCode
+	result.RequestID = line.Response.RequestId
+	result.Response = ResponseFromOpenAI(body, false)
+	result.Response.Provider = OPENAI.String()
+	if body.StatusCode >= http.StatusBadRequest || body.Error.Message != "" {
+		apiErr := newAPIError(OPENAI.String(), body.StatusCode, body.Error.Code, body.Error.Type, body.Error.Message, nil, nil)
+		apiErr.RequestID = line.Response.RequestId
+		result.Err = apiErr
+	}
+	return result
+}
+
+func (c LLMClient) batchClient() (oai.OpenaiClient, error) {
+	if c.provider != OPENAI {
+		return oai.OpenaiClient{}, errors.New("batches not implemented for this provider.")
+	}
+	return c.ToOpenAI()
+}
+
+// SubmitBatch uploads requests and starts processing them as a batch, to
+// be completed within 24 hours.
+func (c LLMClient) SubmitBatch(ctx context.Context, requests []BatchRequest, metadata map[string]string) (Batch, error) {
+	client, err := c.batchClient()
+	if err != nil {
+		return Batch{}, err
+	}
+	input := bytes.Buffer{}
+	err = WriteBatchJSONL(&input, requests)
+	if err != nil {
+		return Batch{}, err
+	}
+
+	file, err := client.UploadFile(ctx, "batch.jsonl", oai.PurposeBatch, &input)
+	if err != nil {
+		return Batch{}, errorFromOpenAI(err)
+	}
+	batch, err := client.CreateBatch(ctx, file.Id, "", metadata)
+	if err != nil {
+		return Batch{}, errorFromOpenAI(err)
+	}
+	return batchFromOpenAI(batch), nil
+}
+
+func (c LLMClient) RetrieveBatch(ctx context.Context, id string) (Batch, error) {
+	client, err := c.batchClient()
+	if err != nil {
+		return Batch{}, err
+	}
+	batch, err := client.RetrieveBatch(ctx, id)
+	if err != nil {
+		return Batch{}, errorFromOpenAI(err)
+	}
+	return batchFromOpenAI(batch), nil
+}
+
+// CancelBatch stops a batch. The requests already processed are still in
+// its results.
+func (c LLMClient) CancelBatch(ctx context.Context, id string) (Batch, error) {
+	client, err := c.batchClient()
+	if err != nil {
+		return Batch{}, err
+	}
+	batch, err := client.CancelBatch(ctx, id)
+	if err != nil {
+		return Batch{}, errorFromOpenAI(err)
+	}
+	return batchFromOpenAI(batch), nil
+}
+
+// WaitBatch polls a batch every interval until it is done or ctx is. An
+// interval <= 0 polls every 30 seconds.
+func (c LLMClient) WaitBatch(ctx context.Context, id string, interval time.Duration) (Batch, error) {
+	client, err := c.batchClient()
+	if err != nil {
+		return Batch{}, err
+	}
+	batch, err := client.WaitBatch(ctx, id, interval)
+	if err != nil {
+		return batchFromOpenAI(batch), errorFromOpenAI(err)
+	}
+	return batchFromOpenAI(batch), nil
+}
+
+// BatchResults downloads the output and error files of a batch and
+// returns the results keyed by custom id.
+func (c LLMClient) BatchResults(ctx context.Context, batch Batch) (map[string]BatchResult, error) {
+	client, err := c.batchClient()
+	if err != nil {
+		return nil, err
+	}
+	results := map[string]BatchResult{}
+	for _, id := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if id == "" {
+			continue
+		}
+		content, err := client.FileContent(ctx, id)
+		if err != nil {
+			return nil, errorFromOpenAI(err)
+		}
+		fileResults, err := ReadBatchResults(content)
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+		for customID, result := range fileResults {
+			results[customID] = result
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,288 @@
+package gollum
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	oai "github.com/azr4e1/gollum/openai"
+)
+
+// BatchOptions configures BatchComplete.
+type BatchOptions struct {
+	// Workers is the number of requests sent concurrently, 4 by default.
+	Workers int
+	// Retries is the number of times a request failing with a transient
+	// error (rate limited, server error, timeout or network) is retried.
+	Retries int
+	// RetryDelay is the delay before the first retry, doubled after each
+	// one, 1 second by default. A delay requested by the provider takes
+	// precedence.
+	RetryDelay time.Duration
+	// Output receives the results as JSONL in the shape of the OpenAI
+	// batch output, readable with ReadBatchResults.
+	Output io.Writer
+	// Checkpoint is a file holding the custom ids of the requests that
+	// succeeded, one per line. Requests found in it are skipped, so that
+	// an interrupted run can be resumed with the same file and an Output
+	// opened for appending.
+	Checkpoint string
+	// Progress is called after each request, one call at a time.
+	Progress func(BatchProgress)
+}
+
+// BatchProgress reports the progress of BatchComplete.
+type BatchProgress struct {
+	Total   int
+	Done    int
+	Failed  int
+	Skipped int
+	// Last is the result of the request that just finished.
+	Last BatchResult
+}
+
+// batchOutputLine is a line of the OpenAI batch output format.
+type batchOutputLine struct {
+	Id       string               `json:"id"`
+	CustomId string               `json:"custom_id"`
+	Response *batchOutputResponse `json:"response"`
+	Error    *oai.CompletionError `json:"error"`
+}
+
+type batchOutputResponse struct {
+	StatusCode int    `json:"status_code"`
+	RequestId  string `json:"request_id"`
+	Body       any    `json:"body"`
+}
+
+type batchOutputBody struct {
+	Id      string              `json:"id"`
+	Object  string              `json:"object"`
+	Created int                 `json:"created"`
+	Model   string              `json:"model"`
+	Choices []batchOutputChoice `json:"choices"`
+	Usage   CompletionUsage     `json:"usage"`
+}
+
+type batchOutputChoice struct {
+	Index        int         `json:"index"`
+	Message      oai.Message `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+type batchOutputError struct {
+	Error CompletionError `json:"error"`
+}
+
+func batchOutput(result BatchResult) batchOutputLine {
+	line := batchOutputLine{Id: "batch_req_" + result.CustomID, CustomId: result.CustomID}
+	if result.Err != nil {
+		var apiErr *APIError
+		if !errors.As(result.Err, &apiErr) || apiErr.StatusCode == 0 {
+			line.Error = &oai.CompletionError{Code: ErrorType(result.Err), Message: result.Err.Error()}
+			return line
+		}
+		line.Response = &batchOutputResponse{
+			StatusCode: apiErr.StatusCode,
+			RequestId:  result.RequestID,
+			Body:       batchOutputError{CompletionError{Message: apiErr.Message, Type: apiErr.Type, Code: apiErr.Code}},
+		}
+		return line
+	}
+
+	res := result.Response
+	message := oai.Message{Role: res.Message.Role, Content: res.Message.Content}
+	for _, tc := range res.Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, oai.NewToolCall(tc.Id, tc.Name, string(tc.Arguments)))
+	}
+	statusCode := res.StatusCode
+	if statusCode == 0 {
+		statusCode = 200
+	}
+	line.Response = &batchOutputResponse{
+		StatusCode: statusCode,
+		RequestId:  result.RequestID,
+		Body: batchOutputBody{
+			Id:      res.Id,
+			Object:  "chat.completion",
+			Created: res.Created,
+			Model:   res.Model,
+			Choices: []batchOutputChoice{{Message: message, FinishReason: res.FinishReason}},
+			Usage:   res.Usage,
+		},
+	}
+	return line
+}
+
+func readCheckpoint(path string) (map[string]bool, error) {
+	done := map[string]bool{}
+	if path == "" {
+		return done, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			done[id] = true
+		}
+	}
+	return done, scanner.Err()
+}
+
+// BatchComplete sends requests with client through a pool of workers, for
+// providers without a batch API or when results are needed sooner. It
+// returns the results of the requests it sent, keyed by custom id. It
+// stops early, with an error, when ctx is done or when writing the output
+// or the checkpoint fails.
+func BatchComplete(ctx context.Context, client LLMClient, requests []BatchRequest, opts BatchOptions) (map[string]BatchResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := checkCustomIDs(requests)
+	if err != nil {
+		return nil, err
+	}
+	done, err := readCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint *os.File
+	if opts.Checkpoint != "" {
+		checkpoint, err = os.OpenFile(opts.Checkpoint, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		defer checkpoint.Close()
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	progress := BatchProgress{Total: len(requests)}
+	todo := []BatchRequest{}
+	for _, r := range requests {
+		if done[r.CustomID] {
+			progress.Skipped++
+			continue
+		}
+		todo = append(todo, r)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := map[string]BatchResult{}
+	var mu sync.Mutex
+	var writeErr error
+	finish := func(result BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		// requests interrupted by the end of the run are sent again on
+		// resume, they are not results
+		if writeErr != nil || runCtx.Err() != nil {
+			return
+		}
+		results[result.CustomID] = result
+		if opts.Output != nil {
+			writeErr = json.NewEncoder(opts.Output).Encode(batchOutput(result))
+		}
+		if writeErr == nil && checkpoint != nil && result.Err == nil {
+			_, writeErr = fmt.Fprintln(checkpoint, result.CustomID)
+		}
+		if writeErr != nil {
+			cancel()
+			return
+		}
+		progress.Done++
+		if result.Err != nil {
+			progress.Failed++
+		}
+		progress.Last = result
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	complete := client.completeFunc()
+	pending := make(chan BatchRequest)
+	wg := sync.WaitGroup{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range pending {
+				finish(sendBatchRequest(runCtx, complete, r, opts))
+			}
+		}()
+	}
+send:
+	for _, r := range todo {
+		select {
+		case pending <- r:
+		case <-runCtx.Done():
+			break send
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	if writeErr != nil {
+		return results, writeErr
+	}
+	return results, ctx.Err()
+}
+
+func sendBatchRequest(ctx context.Context, complete CompleteFunc, r BatchRequest, opts BatchOptions) BatchResult {
+	request := r.Request
+	request.Stream = false
+	if request.Ctx == nil {
+		request.Ctx = ctx
+	}
+	delay := opts.RetryDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := complete(request, nil)
+		result := BatchResult{CustomID: r.CustomID, Response: res, Err: err, RequestID: res.Header.Get("X-Request-Id")}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RequestID != "" {
+			result.RequestID = apiErr.RequestID
+		}
+		// only transient failures, the same that trip a circuit breaker,
+		// are worth retrying
+		if err == nil || attempt >= opts.Retries || !slices.Contains(breakerFailures, ErrorType(err)) {
+			return result
+		}
+
+		wait := delay << attempt
+		if apiErr != nil && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/azr4e1/gollum/retry"
+)
+
+const (
+	endpointURL = "https://bedrock-runtime.%s.amazonaws.com"
+)
+
+const (
+	eventStreamContentType = "application/vnd.amazon.eventstream"
+	errorTypeHeader        = "X-Amzn-Errortype"
+)
+
+type StreamingFunction func(CompletionResponse) error
+
+type BedrockClient struct {
+	region         string
+	credentials    Credentials
+	baseURL        string
+	stream         bool
+	streamFunction StreamingFunction
+	Timeout        time.Duration
+	Retry          retry.Policy
+	// HTTPClient is used to send requests when set. Otherwise a client
+	// with Timeout and the default transport is used.
+	HTTPClient *http.Client
+}
+
+func NewClient(region string, credentials Credentials) (BedrockClient, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return BedrockClient{}, errors.New("Missing AWS region.")
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return BedrockClient{}, errors.New("Missing AWS credentials.")
+	}
+	return BedrockClient{region: region, credentials: credentials, Timeout: 30 * time.Second}, nil
+}
+
+// SetEndpoint overrides the regional bedrock-runtime endpoint, e.g. to use
+// a VPC endpoint or a local fake.
+func (oc *BedrockClient) SetEndpoint(baseURL string) {
+	oc.baseURL = baseURL
+}
+
+func (oc BedrockClient) endpoint() string {
+	if oc.baseURL != "" {
+		return oc.baseURL
+	}
+	return fmt.Sprintf(endpointURL, oc.region)
+}
+
+func (oc BedrockClient) httpClient() *http.Client {
+	if oc.HTTPClient != nil {
+		return oc.HTTPClient
+	}
+	return &http.Client{Timeout: oc.Timeout}
+}
+
+func (oc *BedrockClient) EnableStream(function StreamingFunction) {
+	oc.stream = true
+	oc.streamFunction = function
+}
+
+func (oc BedrockClient) Complete(request *CompletionRequest) (CompletionRequest, CompletionResponse, error) {
+	request.Stream = oc.stream
+
+	res, err := makeHTTPCompletionRequest(request, oc)
+	if err != nil {
+		return *request, CompletionResponse{}, err
+	}
+	defer res.Body.Close()
+
+	if oc.stream {
+		err = oc.readCompletionStreamResponse(res)
+		return *request, CompletionResponse{}, err
+	}
+
+	bedrockRes, err := oc.readCompletionResponse(res)
+	return *request, bedrockRes, err
+}
+
+func (oc BedrockClient) readCompletionResponse(res *http.Response) (CompletionResponse, error) {
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		bedrockRes := readErrorResponse(res, body)
+		return bedrockRes, bedrockRes.err()
+	}
+
+	bedrockRes := new(CompletionResponse)
+	err = json.Unmarshal(body, bedrockRes)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+
+	// attach status code to response object
+	bedrockRes.StatusCode = res.StatusCode
+	bedrockRes.Header = res.Header
+
+	return *bedrockRes, bedrockRes.err()
+}
+
+func (oc BedrockClient) readCompletionStreamResponse(res *http.Response) error {
+
+	if res.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+		return readErrorResponse(res, body).err()
+	}
+
+	decoder := newEventStreamDecoder(res.Body)
+	// tool input arrives as partial JSON strings, keyed by content block
+	toolUses := map[int]*ToolUse{}
+	toolInputs := map[int]*bytes.Buffer{}
+
+	// read response body until end of stream
+	for {
+		event, err := decoder.Decode()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch event.header(":message-type") {
+		case "exception":
+			chunk := readEventError(event.header(":exception-type"), event.Payload)
+			chunk.StatusCode = res.StatusCode
+			chunk.Header = res.Header
+			chunk.body = event.Payload
+			return chunk.err()
+		case "error":
+			chunk := CompletionResponse{
+				Error: CompletionError{
+					Type:    event.header(":error-code"),
+					Message: event.header(":error-message"),
+				},
+				StatusCode: res.StatusCode,
+				Header:     res.Header,
+			}
+			return chunk.err()
+		}
+
+		streamEvent := new(converseStreamEvent)
+		err = json.Unmarshal(event.Payload, streamEvent)
+		if err != nil {
+			return err
+		}
+
+		var chunk *CompletionResponse
+		index := streamEvent.ContentBlockIndex
+		switch event.header(":event-type") {
+		case "contentBlockStart":
+			if start := streamEvent.Start.ToolUse; start != nil {
+				toolUses[index] = start
+				toolInputs[index] = new(bytes.Buffer)
+			}
+		case "contentBlockDelta":
+			if delta := streamEvent.Delta.ToolUse; delta != nil {
+				if input, ok := toolInputs[index]; ok {
+					input.WriteString(delta.Input)
+				}
+				continue
+			}
+			if streamEvent.Delta.Text != "" {
+				chunk = &CompletionResponse{}
+				chunk.Output.Message = Message{Role: Assistant, Content: []ContentBlock{{Text: streamEvent.Delta.Text}}}
+			}
+		case "contentBlockStop":
+			toolUse, ok := toolUses[index]
+			if !ok {
+				continue
+			}
+			input := bytes.TrimSpace(toolInputs[index].Bytes())
+			if len(input) == 0 {
+				input = []byte("{}")
+			}
+			toolUse.Input = json.RawMessage(input)
+			delete(toolUses, index)
+			delete(toolInputs, index)
+			chunk = &CompletionResponse{}
+			chunk.Output.Message = Message{Role: Assistant, Content: []ContentBlock{{ToolUse: toolUse}}}
+		case "messageStop":
+			chunk = &CompletionResponse{StopReason: streamEvent.StopReason}
+		case "metadata":
+			chunk = &CompletionResponse{Usage: streamEvent.Usage, Metrics: streamEvent.Metrics}
+		}
+
+		if chunk == nil {
+			continue
+		}
+		// attach status code to response object
+		chunk.StatusCode = res.StatusCode
+		chunk.Header = res.Header
+
+		err = oc.streamFunction(*chunk)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type converseStreamEvent struct {
+	ContentBlockIndex int `json:"contentBlockIndex"`
+	Start             struct {
+		ToolUse *ToolUse `json:"toolUse"`
+	} `json:"start"`
+	Delta struct {
+		Text    string `json:"text"`
+		ToolUse *struct {
+			Input string `json:"input"`
+		} `json:"toolUse"`
+	} `json:"delta"`
+	StopReason string            `json:"stopReason"`
+	Usage      CompletionUsage   `json:"usage"`
+	Metrics    CompletionMetrics `json:"metrics"`
+}
+
+// readErrorResponse builds an error response from a non 200 reply. The
+// error type is only available in the X-Amzn-Errortype header.
+func readErrorResponse(res *http.Response, body []byte) CompletionResponse {
+	errorType := res.Header.Get(errorTypeHeader)
+	if i := strings.Index(errorType, ":"); i >= 0 {
+		errorType = errorType[:i]
+	}
+	if errorType == "" {
+		errorType = http.StatusText(res.StatusCode)
+	}
+	bedrockRes := readEventError(errorType, body)
+	bedrockRes.StatusCode = res.StatusCode
+	bedrockRes.Header = res.Header
+	bedrockRes.body = body
+
+	return bedrockRes
+}
+
+func readEventError(errorType string, payload []byte) CompletionResponse {
+	// the service is not consistent about the casing of the message key
+	errorBody := struct {
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}{}
+	message := string(bytes.TrimSpace(payload))
+	if json.Unmarshal(payload, &errorBody) == nil {
+		message = errorBody.Message
+		if message == "" {
+			message = errorBody.MessageUpper
+		}
+	}
+
+	return CompletionResponse{Error: CompletionError{Type: errorType, Message: message}}
+}
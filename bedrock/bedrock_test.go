@@ -0,0 +1,300 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testModel = "anthropic.claude-3-haiku-20240307-v1:0"
+
+// encodeFrame builds an application/vnd.amazon.eventstream frame with
+// string headers.
+func encodeFrame(headers map[string]string, payload []byte) []byte {
+	rawHeaders := new(bytes.Buffer)
+	for name, value := range headers {
+		rawHeaders.WriteByte(byte(len(name)))
+		rawHeaders.WriteString(name)
+		rawHeaders.WriteByte(headerString)
+		binary.Write(rawHeaders, binary.BigEndian, uint16(len(value)))
+		rawHeaders.WriteString(value)
+	}
+
+	totalLength := preludeLength + rawHeaders.Len() + len(payload) + checksumLength
+	frame := new(bytes.Buffer)
+	binary.Write(frame, binary.BigEndian, uint32(totalLength))
+	binary.Write(frame, binary.BigEndian, uint32(rawHeaders.Len()))
+	binary.Write(frame, binary.BigEndian, crc32.ChecksumIEEE(frame.Bytes()))
+	frame.Write(rawHeaders.Bytes())
+	frame.Write(payload)
+	binary.Write(frame, binary.BigEndian, crc32.ChecksumIEEE(frame.Bytes()))
+
+	return frame.Bytes()
+}
+
+func eventFrame(eventType, payload string) []byte {
+	headers := map[string]string{
+		":message-type": "event",
+		":event-type":   eventType,
+		":content-type": "application/json",
+	}
+	return encodeFrame(headers, []byte(payload))
+}
+
+// fakeBedrock serves the Converse and ConverseStream operations of a
+// single model. handle receives the decoded request and writes the reply.
+func fakeBedrock(t *testing.T, handle func(w http.ResponseWriter, operation string, request CompletionRequest)) BedrockClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/model/" + uriEncode(testModel) + "/"
+		if !strings.HasPrefix(r.URL.EscapedPath(), prefix) {
+			t.Errorf("unexpected path %s", r.URL.EscapedPath())
+			http.NotFound(w, r)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, signingAlgorithm+" Credential=AKIDEXAMPLE/") {
+			t.Errorf("request not signed: %q", auth)
+		}
+		if r.Header.Get("X-Amz-Date") == "" {
+			t.Error("missing X-Amz-Date")
+		}
+
+		request := CompletionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		handle(w, strings.TrimPrefix(r.URL.EscapedPath(), prefix), request)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("us-east-1", testCredentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetEndpoint(server.URL)
+	return client
+}
+
+func userRequest(text string) *CompletionRequest {
+	return &CompletionRequest{
+		Model:    testModel,
+		Messages: []Message{{Role: User, Content: []ContentBlock{{Text: text}}}},
+	}
+}
+
+func TestConverse(t *testing.T) {
+	client := fakeBedrock(t, func(w http.ResponseWriter, operation string, request CompletionRequest) {
+		if operation != nonStream {
+			t.Errorf("operation = %s", operation)
+		}
+		if got := request.Messages[0].Content[0].Text; got != "Hello" {
+			t.Errorf("message = %q", got)
+		}
+		io.WriteString(w, `{
+			"output": {"message": {"role": "assistant", "content": [{"text": "Hi there"}]}},
+			"stopReason": "end_turn",
+			"usage": {"inputTokens": 3, "outputTokens": 2, "totalTokens": 5},
+			"metrics": {"latencyMs": 120}
+		}`)
+	})
+
+	_, res, err := client.Complete(userRequest("Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Output.Message.Content[0].Text; got != "Hi there" {
+		t.Errorf("text = %q", got)
+	}
+	if res.StopReason != "end_turn" || res.Usage.TotalTokens != 5 || res.StatusCode != http.StatusOK {
+		t.Errorf("unexpected response %+v", res)
+	}
+}
+
+func TestConverseError(t *testing.T) {
+	client := fakeBedrock(t, func(w http.ResponseWriter, operation string, request CompletionRequest) {
+		w.Header().Set(errorTypeHeader, "ThrottlingException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"message": "Too many requests, please wait before trying again."}`)
+	})
+
+	_, _, err := client.Complete(userRequest("Hello"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Type != "ThrottlingException" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	if !strings.HasPrefix(apiErr.Message, "Too many requests") {
+		t.Errorf("message = %q", apiErr.Message)
+	}
+}
+
+func TestConverseStream(t *testing.T) {
+	client := fakeBedrock(t, func(w http.ResponseWriter, operation string, request CompletionRequest) {
+		if operation != stream {
+			t.Errorf("operation = %s", operation)
+		}
+		w.Header().Set("Content-Type", eventStreamContentType)
+		frames := [][]byte{
+			eventFrame("messageStart", `{"role": "assistant"}`),
+			eventFrame("contentBlockDelta", `{"contentBlockIndex": 0, "delta": {"text": "Let me "}}`),
+			eventFrame("contentBlockDelta", `{"contentBlockIndex": 0, "delta": {"text": "check."}}`),
+			eventFrame("contentBlockStop", `{"contentBlockIndex": 0}`),
+			eventFrame("contentBlockStart", `{"contentBlockIndex": 1, "start": {"toolUse": {"toolUseId": "tooluse_1", "name": "weather"}}}`),
+			eventFrame("contentBlockDelta", `{"contentBlockIndex": 1, "delta": {"toolUse": {"input": "{\"city\": "}}}`),
+			eventFrame("contentBlockDelta", `{"contentBlockIndex": 1, "delta": {"toolUse": {"input": "\"Rome\"}"}}}`),
+			eventFrame("contentBlockStop", `{"contentBlockIndex": 1}`),
+			eventFrame("messageStop", `{"stopReason": "tool_use"}`),
+			eventFrame("metadata", `{"usage": {"inputTokens": 10, "outputTokens": 8, "totalTokens": 18}, "metrics": {"latencyMs": 300}}`),
+		}
+		for _, frame := range frames {
+			w.Write(frame)
+		}
+	})
+
+	chunks := []CompletionResponse{}
+	client.EnableStream(func(chunk CompletionResponse) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	_, _, err := client.Complete(userRequest("Weather in Rome?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := ""
+	var toolUse *ToolUse
+	stopReason := ""
+	usage := CompletionUsage{}
+	for _, chunk := range chunks {
+		for _, block := range chunk.Output.Message.Content {
+			text += block.Text
+			if block.ToolUse != nil {
+				toolUse = block.ToolUse
+			}
+		}
+		if chunk.StopReason != "" {
+			stopReason = chunk.StopReason
+		}
+		if chunk.Usage.TotalTokens != 0 {
+			usage = chunk.Usage
+		}
+	}
+	if text != "Let me check." {
+		t.Errorf("text = %q", text)
+	}
+	if toolUse == nil || toolUse.ToolUseId != "tooluse_1" || toolUse.Name != "weather" {
+		t.Fatalf("tool use = %+v", toolUse)
+	}
+	if string(toolUse.Input) != `{"city": "Rome"}` {
+		t.Errorf("tool input = %s", toolUse.Input)
+	}
+	if stopReason != "tool_use" || usage.TotalTokens != 18 {
+		t.Errorf("stop reason = %q, usage = %+v", stopReason, usage)
+	}
+}
+
+func TestConverseStreamException(t *testing.T) {
+	client := fakeBedrock(t, func(w http.ResponseWriter, operation string, request CompletionRequest) {
+		w.Header().Set("Content-Type", eventStreamContentType)
+		w.Write(eventFrame("contentBlockDelta", `{"contentBlockIndex": 0, "delta": {"text": "Hi"}}`))
+		headers := map[string]string{
+			":message-type":   "exception",
+			":exception-type": "modelStreamErrorException",
+			":content-type":   "application/json",
+		}
+		w.Write(encodeFrame(headers, []byte(`{"message": "model failed"}`)))
+	})
+
+	client.EnableStream(func(CompletionResponse) error { return nil })
+	_, _, err := client.Complete(userRequest("Hello"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "modelStreamErrorException" || apiErr.Message != "model failed" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestEventStreamChecksum(t *testing.T) {
+	frame := eventFrame("messageStop", `{"stopReason": "end_turn"}`)
+	frame[len(frame)-6] ^= 0xff
+
+	_, err := newEventStreamDecoder(bytes.NewReader(frame)).Decode()
+	if err == nil {
+		t.Error("expected a checksum error")
+	}
+}
+
+// A tool is used by the model, then its result is sent back in a user
+// message and the model answers with text.
+func TestConverseToolRoundTrip(t *testing.T) {
+	weather := NewTool("weather", "Current weather of a city", map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"city": map[string]any{"type": "string"}},
+	})
+	calls := 0
+	client := fakeBedrock(t, func(w http.ResponseWriter, operation string, request CompletionRequest) {
+		calls++
+		if request.ToolConfig == nil || request.ToolConfig.Tools[0].ToolSpec.Name != "weather" {
+			t.Errorf("missing tool config: %+v", request.ToolConfig)
+		}
+		switch calls {
+		case 1:
+			io.WriteString(w, `{
+				"output": {"message": {"role": "assistant", "content": [
+					{"toolUse": {"toolUseId": "tooluse_1", "name": "weather", "input": {"city": "Rome"}}}
+				]}},
+				"stopReason": "tool_use"
+			}`)
+		case 2:
+			if len(request.Messages) != 3 {
+				t.Fatalf("messages = %+v", request.Messages)
+			}
+			answer := request.Messages[2]
+			if answer.Role != User || len(answer.Content) != 1 || answer.Content[0].ToolResult == nil {
+				t.Fatalf("tool result message = %+v", answer)
+			}
+			result := answer.Content[0].ToolResult
+			if result.ToolUseId != "tooluse_1" || result.Content[0].Text != "sunny, 24C" {
+				t.Errorf("tool result = %+v", result)
+			}
+			io.WriteString(w, `{
+				"output": {"message": {"role": "assistant", "content": [{"text": "It is sunny in Rome."}]}},
+				"stopReason": "end_turn"
+			}`)
+		}
+	})
+
+	request := userRequest("Weather in Rome?")
+	request.ToolConfig = &ToolConfig{Tools: []BedrockTool{weather}}
+	_, res, err := client.Complete(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolUse := res.Output.Message.Content[0].ToolUse
+	if toolUse == nil || string(toolUse.Input) != `{"city": "Rome"}` {
+		t.Fatalf("tool use = %+v", toolUse)
+	}
+
+	request.Messages = append(request.Messages,
+		res.Output.Message,
+		Message{Role: User, Content: []ContentBlock{NewToolResult(toolUse.ToolUseId, "sunny, 24C")}},
+	)
+	_, res, err = client.Complete(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Output.Message.Content[0].Text; got != "It is sunny in Rome." {
+		t.Errorf("text = %q", got)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d", calls)
+	}
+}
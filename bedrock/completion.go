@@ -0,0 +1,123 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	nonStream = "converse"
+	stream    = "converse-stream"
+)
+
+type InferenceConfig struct {
+	MaxTokens     *int     `json:"maxTokens,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+type CompletionRequest struct {
+	Model           string           `json:"-"`
+	Messages        []Message        `json:"messages"`
+	System          []SystemMessage  `json:"system,omitempty"`
+	InferenceConfig *InferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig      *ToolConfig      `json:"toolConfig,omitempty"`
+	// AdditionalModelRequestFields holds the parameters specific to the
+	// model that the Converse API does not define, e.g. top_k.
+	AdditionalModelRequestFields map[string]any  `json:"additionalModelRequestFields,omitempty"`
+	Stream                       bool            `json:"-"`
+	Ctx                          context.Context `json:"-"`
+}
+
+type CompletionUsage struct {
+	PromptTokens     int `json:"inputTokens"`
+	CompletionTokens int `json:"outputTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+type CompletionMetrics struct {
+	LatencyMs int `json:"latencyMs"`
+}
+
+type CompletionOutput struct {
+	Message Message `json:"message"`
+}
+
+type CompletionError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+type CompletionResponse struct {
+	Output     CompletionOutput  `json:"output"`
+	StopReason string            `json:"stopReason"`
+	Usage      CompletionUsage   `json:"usage"`
+	Metrics    CompletionMetrics `json:"metrics"`
+	Error      CompletionError   `json:"error,omitempty"`
+	StatusCode int               `json:"status_code"`
+	Header     http.Header       `json:"-"`
+	body       []byte
+}
+
+func (or CompletionResponse) err() error {
+	if or.Error.Type == "" && or.Error.Message == "" && or.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	return &APIError{
+		StatusCode: or.StatusCode,
+		Type:       or.Error.Type,
+		Message:    or.Error.Message,
+		Header:     or.Header,
+		Body:       or.body,
+	}
+}
+
+func makeHTTPCompletionRequest(request *CompletionRequest, oc BedrockClient) (*http.Response, error) {
+	if request.Model == "" {
+		return nil, errors.New("Missing model ID.")
+	}
+	jsonRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	operation := nonStream
+	if oc.stream {
+		operation = stream
+	}
+	endpoint, err := url.Parse(oc.endpoint())
+	if err != nil {
+		return nil, err
+	}
+	// model IDs contain ':' which has to be escaped in the request path
+	basePath := strings.TrimSuffix(endpoint.EscapedPath(), "/")
+	endpoint.RawPath = basePath + "/" + strings.Join([]string{"model", uriEncode(request.Model), operation}, "/")
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + strings.Join([]string{"model", request.Model, operation}, "/")
+	req, err := http.NewRequest(http.MethodPost, endpoint.String(), bytes.NewReader(jsonRequest))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if oc.stream {
+		req.Header.Set("Accept", eventStreamContentType)
+	}
+	err = SignRequest(req, jsonRequest, oc.credentials, oc.region, signingService, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if request.Ctx != nil {
+		req = req.WithContext(request.Ctx)
+	}
+
+	res, err := oc.Retry.Do(oc.httpClient(), req)
+
+	return res, err
+}
@@ -0,0 +1,20 @@
+package bedrock
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIError is returned when Bedrock replies with an error payload, a non
+// successful status code or an exception frame in a stream.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
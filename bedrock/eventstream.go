@@ -0,0 +1,160 @@
+package bedrock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	preludeLength  = 12
+	checksumLength = 4
+	// frames larger than this are rejected rather than allocated
+	maxFrameLength = 16 * 1024 * 1024
+)
+
+const (
+	headerBoolTrue = iota
+	headerBoolFalse
+	headerByte
+	headerShort
+	headerInt
+	headerLong
+	headerBytes
+	headerString
+	headerTimestamp
+	headerUUID
+)
+
+// eventMessage is a single frame of an application/vnd.amazon.eventstream
+// response body.
+type eventMessage struct {
+	Headers map[string]any
+	Payload []byte
+}
+
+func (em eventMessage) header(name string) string {
+	value, ok := em.Headers[name].(string)
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+type eventStreamDecoder struct {
+	reader io.Reader
+}
+
+func newEventStreamDecoder(reader io.Reader) *eventStreamDecoder {
+	return &eventStreamDecoder{reader: reader}
+}
+
+// Decode reads the next frame from the stream. It returns io.EOF when the
+// stream ends cleanly between two frames.
+func (d *eventStreamDecoder) Decode() (eventMessage, error) {
+	prelude := make([]byte, preludeLength)
+	if _, err := io.ReadFull(d.reader, prelude); err != nil {
+		return eventMessage{}, err
+	}
+
+	totalLength := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	preludeCRC := binary.BigEndian.Uint32(prelude[8:12])
+	if crc32.ChecksumIEEE(prelude[0:8]) != preludeCRC {
+		return eventMessage{}, errors.New("event stream prelude checksum mismatch.")
+	}
+	if totalLength > maxFrameLength || totalLength < preludeLength+checksumLength+headersLength {
+		return eventMessage{}, fmt.Errorf("invalid event stream frame length %d.", totalLength)
+	}
+
+	rest := make([]byte, totalLength-preludeLength)
+	if _, err := io.ReadFull(d.reader, rest); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return eventMessage{}, err
+	}
+
+	messageCRC := binary.BigEndian.Uint32(rest[len(rest)-checksumLength:])
+	crc := crc32.NewIEEE()
+	crc.Write(prelude)
+	crc.Write(rest[:len(rest)-checksumLength])
+	if crc.Sum32() != messageCRC {
+		return eventMessage{}, errors.New("event stream message checksum mismatch.")
+	}
+
+	headers, err := decodeHeaders(rest[:headersLength])
+	if err != nil {
+		return eventMessage{}, err
+	}
+	payload := rest[headersLength : len(rest)-checksumLength]
+
+	return eventMessage{Headers: headers, Payload: payload}, nil
+}
+
+func decodeHeaders(raw []byte) (map[string]any, error) {
+	headers := map[string]any{}
+	truncated := errors.New("truncated event stream header.")
+
+	for len(raw) > 0 {
+		nameLength := int(raw[0])
+		if len(raw) < 1+nameLength+1 {
+			return nil, truncated
+		}
+		name := string(raw[1 : 1+nameLength])
+		valueType := raw[1+nameLength]
+		raw = raw[2+nameLength:]
+
+		var size int
+		switch valueType {
+		case headerBoolTrue, headerBoolFalse:
+			size = 0
+		case headerByte:
+			size = 1
+		case headerShort:
+			size = 2
+		case headerInt:
+			size = 4
+		case headerLong, headerTimestamp:
+			size = 8
+		case headerUUID:
+			size = 16
+		case headerBytes, headerString:
+			if len(raw) < 2 {
+				return nil, truncated
+			}
+			size = int(binary.BigEndian.Uint16(raw[0:2]))
+			raw = raw[2:]
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d.", valueType)
+		}
+		if len(raw) < size {
+			return nil, truncated
+		}
+		value := raw[:size]
+		raw = raw[size:]
+
+		switch valueType {
+		case headerBoolTrue:
+			headers[name] = true
+		case headerBoolFalse:
+			headers[name] = false
+		case headerByte:
+			headers[name] = int8(value[0])
+		case headerShort:
+			headers[name] = int16(binary.BigEndian.Uint16(value))
+		case headerInt:
+			headers[name] = int32(binary.BigEndian.Uint32(value))
+		case headerLong, headerTimestamp:
+			headers[name] = int64(binary.BigEndian.Uint64(value))
+		case headerString:
+			headers[name] = string(value)
+		default:
+			headers[name] = append([]byte(nil), value...)
+		}
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,48 @@
+package bedrock
+
+import "encoding/json"
+
+const (
+	Assistant = "assistant"
+	User      = "user"
+)
+
+type Message struct {
+	Role    string         `json:"role"`
+	Content []ContentBlock `json:"content"`
+}
+
+type ContentBlock struct {
+	Text       string      `json:"text,omitempty"`
+	ToolUse    *ToolUse    `json:"toolUse,omitempty"`
+	ToolResult *ToolResult `json:"toolResult,omitempty"`
+}
+
+type ToolUse struct {
+	ToolUseId string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+// ToolResult answers a ToolUse. It is sent in a user message, one block per
+// tool used by the previous assistant message.
+type ToolResult struct {
+	ToolUseId string              `json:"toolUseId"`
+	Content   []ToolResultContent `json:"content"`
+	Status    string              `json:"status,omitempty"`
+}
+
+type ToolResultContent struct {
+	Text string          `json:"text,omitempty"`
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+// NewToolResult answers the tool use toolUseId with a text result.
+func NewToolResult(toolUseId, text string) ContentBlock {
+	result := &ToolResult{ToolUseId: toolUseId, Content: []ToolResultContent{{Text: text}}}
+	return ContentBlock{ToolResult: result}
+}
+
+type SystemMessage struct {
+	Text string `json:"text"`
+}
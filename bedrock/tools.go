@@ -0,0 +1,31 @@
+package bedrock
+
+type ToolConfig struct {
+	Tools []BedrockTool `json:"tools"`
+}
+
+type BedrockTool struct {
+	ToolSpec toolSpec `json:"toolSpec"`
+}
+
+type toolSpec struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema inputSchema `json:"inputSchema"`
+}
+
+type inputSchema struct {
+	JSON any `json:"json"`
+}
+
+// NewTool creates a tool specification for the Converse API. schema must
+// marshal to a JSON schema object describing the tool input.
+func NewTool(name, description string, schema any) BedrockTool {
+	spec := toolSpec{
+		Name:        name,
+		Description: description,
+		InputSchema: inputSchema{JSON: schema},
+	}
+
+	return BedrockTool{ToolSpec: spec}
+}
@@ -0,0 +1,157 @@
+package gollum
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (cs CircuitState) String() string {
+	switch cs {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breakerFailures are the error classes, as returned by ErrorType, that
+// count as failures of the provider. Client errors such as a bad request
+// or an exhausted budget do not.
+var breakerFailures = []string{"rate_limited", "server_error", "timeout", "network"}
+
+// CircuitBreaker stops sending requests to a provider after consecutive
+// failures. Once open, requests fail with ErrCircuitOpen until the cooldown
+// has passed, then a single trial request is let through: the circuit
+// closes again if it succeeds and reopens if it fails.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	failures int
+	cooldown time.Duration
+	state    CircuitState
+	count    int
+	openedAt time.Time
+	trial    bool
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+func NewCircuitBreaker(failures int, cooldown time.Duration) (*CircuitBreaker, error) {
+	if failures <= 0 {
+		return nil, errors.New("circuit breaker failures must be greater than 0.")
+	}
+	if cooldown <= 0 {
+		return nil, errors.New("circuit breaker cooldown must be greater than 0.")
+	}
+	return &CircuitBreaker{failures: failures, cooldown: cooldown, now: time.Now}, nil
+}
+
+// State returns the state of the circuit, for health checks.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.cooldown {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// allow reports whether a request can be sent, and moves an open circuit
+// whose cooldown has passed to half open.
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = CircuitHalfOpen
+		cb.trial = true
+		return true
+	case CircuitHalfOpen:
+		// only the trial request is let through
+		if cb.trial {
+			return false
+		}
+		cb.trial = true
+		return true
+	}
+	return true
+}
+
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	// a canceled request, e.g. the loser of a hedged request, tells nothing
+	// about the provider
+	if ErrorType(err) == "canceled" {
+		cb.trial = false
+		return
+	}
+	failed := err != nil && slices.Contains(breakerFailures, ErrorType(err))
+	if cb.state == CircuitHalfOpen {
+		cb.trial = false
+		if failed {
+			cb.state = CircuitOpen
+			cb.openedAt = cb.now()
+			return
+		}
+		cb.state = CircuitClosed
+		cb.count = 0
+		return
+	}
+
+	if !failed {
+		cb.count = 0
+		return
+	}
+	cb.count++
+	if cb.count >= cb.failures {
+		cb.state = CircuitOpen
+		cb.openedAt = cb.now()
+	}
+}
+
+// Reset closes the circuit.
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = CircuitClosed
+	cb.count = 0
+	cb.trial = false
+}
+
+func (cb *CircuitBreaker) middleware(next CompleteFunc) CompleteFunc {
+	return func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+		if !cb.allow() {
+			return CompletionResponse{}, ErrCircuitOpen
+		}
+		res, err := next(request, stream)
+		cb.record(err)
+		return res, err
+	}
+}
+
+// CircuitState returns the state of the circuit breaker of the client, or
+// CircuitClosed when it has none.
+func (c LLMClient) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.State()
+}
@@ -0,0 +1,118 @@
+package gollum
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock only moving when advanced.
+type fakeClock struct{ t time.Time }
+
+func (fc *fakeClock) now() time.Time          { return fc.t }
+func (fc *fakeClock) advance(d time.Duration) { fc.t = fc.t.Add(d) }
+
+func newTestBreaker(t *testing.T, failures int, cooldown time.Duration) (*CircuitBreaker, *fakeClock) {
+	t.Helper()
+	cb, err := NewCircuitBreaker(failures, cooldown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	cb.now = clock.now
+	return cb, clock
+}
+
+// breakerCall sends a request through the breaker, answered with err, and
+// reports whether it reached the provider.
+func breakerCall(cb *CircuitBreaker, err error) (bool, error) {
+	sent := false
+	_, err = cb.middleware(func(CompletionRequest, StreamingFunction) (CompletionResponse, error) {
+		sent = true
+		return CompletionResponse{}, err
+	})(CompletionRequest{}, nil)
+	return sent, err
+}
+
+func checkState(t *testing.T, cb *CircuitBreaker, want CircuitState) {
+	t.Helper()
+	if state := cb.State(); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+}
+
+var errServer = &APIError{StatusCode: http.StatusInternalServerError}
+
+func TestBreakerTransitions(t *testing.T) {
+	cb, clock := newTestBreaker(t, 2, time.Minute)
+
+	// a success resets the consecutive failures
+	breakerCall(cb, errServer)
+	breakerCall(cb, nil)
+	breakerCall(cb, errServer)
+	checkState(t, cb, CircuitClosed)
+
+	breakerCall(cb, errServer)
+	checkState(t, cb, CircuitOpen)
+	if sent, err := breakerCall(cb, nil); sent || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open circuit: sent %v, error %v", sent, err)
+	}
+
+	clock.advance(time.Minute)
+	checkState(t, cb, CircuitHalfOpen)
+	// a failed trial reopens the circuit for another cooldown
+	if sent, _ := breakerCall(cb, errServer); !sent {
+		t.Fatal("the trial request was not sent")
+	}
+	checkState(t, cb, CircuitOpen)
+	clock.advance(time.Minute - time.Second)
+	checkState(t, cb, CircuitOpen)
+
+	clock.advance(time.Second)
+	if sent, err := breakerCall(cb, nil); !sent || err != nil {
+		t.Fatalf("trial request: sent %v, error %v", sent, err)
+	}
+	checkState(t, cb, CircuitClosed)
+}
+
+// Only one trial request is let through while the circuit is half open.
+func TestBreakerSingleTrial(t *testing.T) {
+	cb, clock := newTestBreaker(t, 1, time.Minute)
+	breakerCall(cb, errServer)
+	clock.advance(time.Minute)
+
+	var concurrent bool
+	var concurrentErr error
+	_, err := cb.middleware(func(CompletionRequest, StreamingFunction) (CompletionResponse, error) {
+		concurrent, concurrentErr = breakerCall(cb, nil)
+		return CompletionResponse{}, nil
+	})(CompletionRequest{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if concurrent || !errors.Is(concurrentErr, ErrCircuitOpen) {
+		t.Errorf("second request during the trial: sent %v, error %v", concurrent, concurrentErr)
+	}
+	checkState(t, cb, CircuitClosed)
+}
+
+// Client errors and canceled requests do not open the circuit, and a
+// canceled trial lets the next request be the trial.
+func TestBreakerIgnoredErrors(t *testing.T) {
+	cb, clock := newTestBreaker(t, 1, time.Minute)
+	breakerCall(cb, &APIError{StatusCode: http.StatusBadRequest})
+	breakerCall(cb, ErrBudgetExceeded)
+	checkState(t, cb, CircuitClosed)
+
+	breakerCall(cb, ErrRateLimited)
+	checkState(t, cb, CircuitOpen)
+	clock.advance(time.Minute)
+	breakerCall(cb, context.Canceled)
+	checkState(t, cb, CircuitHalfOpen)
+	if sent, _ := breakerCall(cb, nil); !sent {
+		t.Fatal("no trial request after a canceled one")
+	}
+	checkState(t, cb, CircuitClosed)
+}
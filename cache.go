@@ -0,0 +1,334 @@
+package gollum
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	m "github.com/azr4e1/gollum/message"
+)
+
+// CacheEntry is a cached completion. Chunks holds the stream chunks when
+// the completion was streamed.
+type CacheEntry struct {
+	Response CompletionResponse   `json:"response"`
+	Chunks   []CompletionResponse `json:"chunks,omitempty"`
+	// Expires is the zero time when the entry never expires.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+func (ce CacheEntry) expired(now time.Time) bool {
+	return !ce.Expires.IsZero() && now.After(ce.Expires)
+}
+
+// Cache stores completions by key. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	Get(key string) (CacheEntry, bool, error)
+	Set(key string, entry CacheEntry) error
+	Delete(key string) error
+}
+
+// CacheKey returns the canonical hash of a request for provider. The
+// context, the stream flag and the cache bypass flag are not part of the
+// key, so streamed and plain completions share their entries.
+func CacheKey(provider string, request CompletionRequest) (string, error) {
+	request.Stream = false
+	body, err := json.Marshal(struct {
+		Provider string            `json:"provider"`
+		Request  CompletionRequest `json:"request"`
+	}{provider, request})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// MemoryCache is an in-memory cache that evicts the least recently used
+// entries beyond its size.
+type MemoryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	entry CacheEntry
+}
+
+func NewMemoryCache(size int) (*MemoryCache, error) {
+	if size <= 0 {
+		return nil, errors.New("cache size must be greater than 0.")
+	}
+	return &MemoryCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}, nil
+}
+
+func (mc *MemoryCache) Get(key string) (CacheEntry, bool, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	elem, ok := mc.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+	mc.order.MoveToFront(elem)
+	return elem.Value.(*memoryItem).entry, true, nil
+}
+
+func (mc *MemoryCache) Set(key string, entry CacheEntry) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if elem, ok := mc.entries[key]; ok {
+		elem.Value.(*memoryItem).entry = entry
+		mc.order.MoveToFront(elem)
+		return nil
+	}
+	mc.entries[key] = mc.order.PushFront(&memoryItem{key: key, entry: entry})
+	for mc.order.Len() > mc.size {
+		oldest := mc.order.Back()
+		mc.order.Remove(oldest)
+		delete(mc.entries, oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
+
+func (mc *MemoryCache) Delete(key string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if elem, ok := mc.entries[key]; ok {
+		mc.order.Remove(elem)
+		delete(mc.entries, key)
+	}
+	return nil
+}
+
+func (mc *MemoryCache) Len() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.order.Len()
+}
+
+// DiskCache stores every entry as a JSON file in a directory, so that it
+// survives restarts and can be shared by several processes.
+type DiskCache struct {
+	dir string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory is empty.")
+	}
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (dc *DiskCache) path(key string) string {
+	return filepath.Join(dc.dir, key+".json")
+}
+
+func (dc *DiskCache) Get(key string) (CacheEntry, bool, error) {
+	body, err := os.ReadFile(dc.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+
+	entry := CacheEntry{}
+	err = json.Unmarshal(body, &entry)
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// Set writes the entry to a temporary file first, so that concurrent
+// readers never see a partial entry.
+func (dc *DiskCache) Set(key string, entry CacheEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dc.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dc.path(key))
+}
+
+func (dc *DiskCache) Delete(key string) error {
+	err := os.Remove(dc.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// cacheResponses answers from cache when possible and stores successful
+// completions. Cache failures are treated as misses, they never fail the
+// completion itself.
+func cacheResponses(provider llmProvider, cache Cache, ttl time.Duration) Middleware {
+	return func(next CompleteFunc) CompleteFunc {
+		return func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+			key, err := CacheKey(provider.String(), request)
+			if err != nil {
+				return next(request, stream)
+			}
+
+			if !request.CacheBypass {
+				entry, ok, err := cache.Get(key)
+				if err == nil && ok && !entry.expired(time.Now()) {
+					return replayEntry(entry, stream)
+				}
+			}
+
+			var chunks []CompletionResponse
+			if stream != nil {
+				streamFunction := stream
+				stream = func(res CompletionResponse) error {
+					chunks = append(chunks, res)
+					return streamFunction(res)
+				}
+			}
+
+			res, err := next(request, stream)
+			if err != nil {
+				return res, err
+			}
+
+			entry := CacheEntry{Response: res, Chunks: chunks}
+			if stream != nil {
+				entry.Response = JoinChunks(chunks)
+			}
+			if ttl > 0 {
+				entry.Expires = time.Now().Add(ttl)
+			}
+			cache.Set(key, entry)
+
+			return res, nil
+		}
+	}
+}
+
+// replayEntry passes the cached chunks to stream, or the whole response as
+// a single chunk when the entry was not streamed.
+func replayEntry(entry CacheEntry, stream StreamingFunction) (CompletionResponse, error) {
+	if stream == nil {
+		res := entry.Response
+		res.Cached = true
+		return res, nil
+	}
+
+	chunks := entry.Chunks
+	if len(chunks) == 0 {
+		chunks = []CompletionResponse{entry.Response}
+	}
+	for _, chunk := range chunks {
+		chunk.Cached = true
+		err := stream(chunk)
+		if err != nil {
+			return CompletionResponse{}, err
+		}
+	}
+	return CompletionResponse{}, nil
+}
+
+// JoinChunks rebuilds a whole response from stream chunks, merging the
+// fragments of streamed tool calls.
+func JoinChunks(chunks []CompletionResponse) CompletionResponse {
+	res := CompletionResponse{}
+	for _, chunk := range chunks {
+		content := res.Message.Content + chunk.Message.Content
+		toolCalls := mergeToolCalls(res.Message.ToolCalls, chunk.Message.ToolCalls)
+		if chunk.Id != "" {
+			res.Id = chunk.Id
+		}
+		if chunk.Model != "" {
+			res.Model = chunk.Model
+		}
+		if chunk.Message.Role != "" {
+			res.Message.Role = chunk.Message.Role
+		}
+		if chunk.FinishReason != "" {
+			res.FinishReason = chunk.FinishReason
+		}
+		if chunk.Usage.TotalTokens > 0 {
+			res.Usage = chunk.Usage
+		}
+		if chunk.Type != "" {
+			res.Type = chunk.Type
+		}
+		res.Object = chunk.Object
+		res.Created = chunk.Created
+		res.StatusCode = chunk.StatusCode
+		res.Provider = chunk.Provider
+		res.Done = chunk.Done
+		res.Message.Content = content
+		res.Message.ToolCalls = toolCalls
+	}
+	for i := range res.Message.ToolCalls {
+		res.Message.ToolCalls[i].Index = nil
+	}
+	return res
+}
+
+// mergeToolCalls adds the tool calls of a chunk to calls. A call with the
+// index or id of a previous one is a fragment of it: its arguments are
+// appended, as OpenAI streams them a few characters at a time.
+func mergeToolCalls(calls []m.ToolCall, chunkCalls []m.ToolCall) []m.ToolCall {
+	for _, tc := range chunkCalls {
+		i := slices.IndexFunc(calls, func(call m.ToolCall) bool {
+			if tc.Index != nil {
+				return call.Index != nil && *call.Index == *tc.Index
+			}
+			return tc.Id != "" && call.Id == tc.Id
+		})
+		if i < 0 {
+			tc.Arguments = append(json.RawMessage(nil), tc.Arguments...)
+			calls = append(calls, tc)
+			continue
+		}
+		if tc.Id != "" {
+			calls[i].Id = tc.Id
+		}
+		if tc.Type != "" {
+			calls[i].Type = tc.Type
+		}
+		if tc.Name != "" {
+			calls[i].Name = tc.Name
+		}
+		calls[i].Arguments = append(calls[i].Arguments, tc.Arguments...)
+	}
+	return calls
+}
@@ -2,7 +2,11 @@ package gollum
 
 import (
 	"errors"
+	"log/slog"
+	"net/http"
 	"time"
+
+	"github.com/azr4e1/gollum/retry"
 )
 
 type llmProvider int
@@ -11,22 +15,57 @@ const (
 	OPENAI llmProvider = iota + 1
 	OLLAMA
 	GEMINI
+	BEDROCK
 )
 
+func (p llmProvider) String() string {
+	switch p {
+	case OPENAI:
+		return "openai"
+	case OLLAMA:
+		return "ollama"
+	case GEMINI:
+		return "gemini"
+	case BEDROCK:
+		return "bedrock"
+	}
+	return ""
+}
+
+// defaultTimeout is the Timeout of a new client.
+const defaultTimeout = 30 * time.Second
+
 type StreamingFunction func(CompletionResponse) error
 
 type LLMClient struct {
-	provider       llmProvider
-	apiKey         string
-	apiBase        string
-	stream         bool
-	streamFunction StreamingFunction
-	Timeout        time.Duration
+	provider         llmProvider
+	apiKey           string
+	apiBase          string
+	region           string
+	stream           bool
+	streamFunction   StreamingFunction
+	retry            retry.Policy
+	limiter          *RateLimiter
+	metrics          MetricsCollector
+	costs            *CostTracker
+	budget           *Budget
+	cache            Cache
+	cacheTTL         time.Duration
+	semanticCache    *SemanticCache
+	breaker          *CircuitBreaker
+	middleware       []Middleware
+	speechMiddleware []SpeechMiddleware
+	logger           *slog.Logger
+	logBodies        bool
+	redactContent    func(string) string
+	client           *http.Client
+	transport        http.RoundTripper
+	Timeout          time.Duration
 }
 
 func NewClient(options ...clientOption) (LLMClient, error) {
 	client := new(LLMClient)
-	client.Timeout = 30 * time.Second
+	client.Timeout = defaultTimeout
 	for _, o := range options {
 		err := o(client)
 		if err != nil {
@@ -37,13 +76,23 @@ func NewClient(options ...clientOption) (LLMClient, error) {
 	if client.provider == 0 {
 		return LLMClient{}, errors.New("provider is empty.")
 	}
-	if client.apiKey == "" && client.apiBase == "" {
+	// bedrock authenticates with AWS credentials from the environment
+	if client.provider != BEDROCK && client.apiKey == "" && client.apiBase == "" {
 		return LLMClient{}, errors.New("must provide at least one of apiKey or apiBase")
 	}
 
 	return *client, nil
 }
 
+func (c LLMClient) requestLogger() requestLogger {
+	return requestLogger{
+		logger:        c.logger,
+		provider:      c.provider,
+		apiKey:        c.apiKey,
+		redactContent: c.redactContent,
+	}
+}
+
 func (oc *LLMClient) DisableStream() {
 	oc.stream = false
 	oc.streamFunction = nil
@@ -64,6 +113,13 @@ func (c LLMClient) Complete(options ...completionOption) (CompletionRequest, Com
 		return *request, CompletionResponse{}, err
 	}
 
+	request.Stream = c.stream
+
+	res, err := c.completeFunc()(*request, c.streamFunction)
+	return *request, res, err
+}
+
+func (c LLMClient) complete(request *CompletionRequest) (CompletionRequest, CompletionResponse, error) {
 	switch c.provider {
 	case OPENAI:
 		return openaiComplete(request, c)
@@ -71,6 +127,8 @@ func (c LLMClient) Complete(options ...completionOption) (CompletionRequest, Com
 		return geminiComplete(request, c)
 	case OLLAMA:
 		return ollamaComplete(request, c)
+	case BEDROCK:
+		return bedrockComplete(request, c)
 	}
 
 	return *request, CompletionResponse{}, errors.New("completion not implemented for this provider.")
@@ -82,6 +140,11 @@ func (c LLMClient) TextToSpeech(options ...speechOption) (TTSRequest, TTSRespons
 		return *request, TTSResponse{}, err
 	}
 
+	res, err := c.speechFunc()(*request)
+	return *request, res, err
+}
+
+func (c LLMClient) textToSpeech(request *TTSRequest) (TTSRequest, TTSResponse, error) {
 	switch c.provider {
 	case OPENAI:
 		return openaiTTS(request, c)
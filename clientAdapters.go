@@ -1,6 +1,7 @@
 package gollum
 
 import (
+	br "github.com/azr4e1/gollum/bedrock"
 	gem "github.com/azr4e1/gollum/gemini"
 	ll "github.com/azr4e1/gollum/ollama"
 	oai "github.com/azr4e1/gollum/openai"
@@ -12,6 +13,8 @@ func (c LLMClient) ToOpenAI() (oai.OpenaiClient, error) {
 		return oai.OpenaiClient{}, err
 	}
 	client.Timeout = c.Timeout
+	client.Retry = c.retry
+	client.HTTPClient = c.httpClient()
 
 	return client, nil
 }
@@ -22,6 +25,8 @@ func (c LLMClient) ToGemini() (gem.GeminiClient, error) {
 		return gem.GeminiClient{}, err
 	}
 	client.Timeout = c.Timeout
+	client.Retry = c.retry
+	client.HTTPClient = c.httpClient()
 
 	return client, nil
 }
@@ -32,6 +37,27 @@ func (c LLMClient) ToOllama() (ll.OllamaClient, error) {
 		return ll.OllamaClient{}, err
 	}
 	client.Timeout = c.Timeout
+	client.Retry = c.retry
+	client.HTTPClient = c.httpClient()
+
+	return client, nil
+}
+
+func (c LLMClient) ToBedrock() (br.BedrockClient, error) {
+	credentials, err := br.CredentialsFromEnv()
+	if err != nil {
+		return br.BedrockClient{}, err
+	}
+	client, err := br.NewClient(c.region, credentials)
+	if err != nil {
+		return br.BedrockClient{}, err
+	}
+	if c.apiBase != "" {
+		client.SetEndpoint(c.apiBase)
+	}
+	client.Timeout = c.Timeout
+	client.Retry = c.retry
+	client.HTTPClient = c.httpClient()
 
 	return client, nil
 }
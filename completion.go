@@ -3,7 +3,7 @@ package gollum
 import (
 	"context"
 	"errors"
-	"fmt"
+	"net/http"
 
 	m "github.com/azr4e1/gollum/message"
 )
@@ -34,6 +34,8 @@ type CompletionRequest struct {
 	TopK                *int            `json:"top_k,omitempty"`
 	User                string          `json:"user,omitempty"`
 	Ctx                 context.Context `json:"-"`
+	// CacheBypass skips the cache lookup. The response is still stored.
+	CacheBypass bool `json:"-"`
 }
 
 type CompletionUsage struct {
@@ -46,19 +48,26 @@ type CompletionUsage struct {
 type CompletionError struct {
 	Message string `json:"message"`
 	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
 }
 
 type CompletionResponse struct {
-	Id         string          `json:"id"`
-	Object     string          `json:"object"`
-	Created    int             `json:"created"`
-	Model      string          `json:"model"`
-	Type       CompletionType  `json:"type"`
-	Message    m.Message       `json:"message"`
-	Done       bool            `json:"done"`
-	Usage      CompletionUsage `json:"usage"`
-	Error      CompletionError `json:"error,omitempty"`
-	StatusCode int             `json:"status_code"`
+	Id           string          `json:"id"`
+	Object       string          `json:"object"`
+	Created      int             `json:"created"`
+	Model        string          `json:"model"`
+	Type         CompletionType  `json:"type"`
+	Message      m.Message       `json:"message"`
+	Done         bool            `json:"done"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+	Usage        CompletionUsage `json:"usage"`
+	Error        CompletionError `json:"error,omitempty"`
+	StatusCode   int             `json:"status_code"`
+	Provider     string          `json:"provider,omitempty"`
+	Cached       bool            `json:"cached,omitempty"`
+	Header       http.Header     `json:"-"`
+	// body is the raw error reply of the provider
+	body []byte
 }
 
 func (or CompletionResponse) Content() string {
@@ -77,10 +86,14 @@ func (or CompletionResponse) Tool() (m.ToolCall, error) {
 }
 
 func (or CompletionResponse) Err() error {
-	if or.Error.Type == "" && or.Error.Message == "" {
+	if or.Error.Type == "" && or.Error.Message == "" && or.StatusCode < http.StatusBadRequest {
 		return nil
 	}
-	return errors.New(fmt.Sprintf("%s: %s", or.Error.Type, or.Error.Message))
+	message := or.Error.Message
+	if message == "" && or.Error.Type == "" {
+		message = http.StatusText(or.StatusCode)
+	}
+	return newAPIError(or.Provider, or.StatusCode, or.Error.Code, or.Error.Type, message, or.Header, or.body)
 }
 
 func NewCompletionRequest(options ...completionOption) (*CompletionRequest, error) {
@@ -1,9 +1,12 @@
 package gollum
 
 import (
+	"bytes"
 	"encoding/json"
+	"strings"
 	"time"
 
+	br "github.com/azr4e1/gollum/bedrock"
 	gem "github.com/azr4e1/gollum/gemini"
 	m "github.com/azr4e1/gollum/message"
 	ll "github.com/azr4e1/gollum/ollama"
@@ -17,7 +20,11 @@ func (cr CompletionRequest) ToOpenAI() oai.CompletionRequest {
 		messages = append(messages, systemMessage)
 	}
 	for _, mess := range cr.Messages {
-		messages = append(messages, oai.Message{Role: mess.Role, Content: mess.Content})
+		openaiMess := oai.Message{Role: mess.Role, Content: mess.Content, ToolCallId: mess.ToolCallId}
+		for _, tc := range mess.ToolCalls {
+			openaiMess.ToolCalls = append(openaiMess.ToolCalls, oai.NewToolCall(tc.Id, tc.Name, string(tc.Arguments)))
+		}
+		messages = append(messages, openaiMess)
 	}
 	tools := []oai.OpenaiTool{}
 	for _, t := range cr.Tools {
@@ -55,12 +62,27 @@ func (cr CompletionRequest) ToGemini() gem.CompletionRequest {
 		"system":    "system",
 		"user":      "user",
 	}
+	names := toolCallNames(cr.Messages)
 	messages := []gem.Message{}
 	for _, mess := range cr.Messages {
-		part := [](map[string]string){
-			{"text": mess.Content},
+		if mess.Role == "tool" {
+			// the results of tools called together go in a single message
+			part := gem.FunctionResponsePart(names[mess.ToolCallId], mess.Content)
+			if last := len(messages) - 1; last >= 0 && isFunctionResponse(messages[last]) {
+				messages[last].Part = append(messages[last].Part, part)
+				continue
+			}
+			messages = append(messages, gem.Message{Role: gem.User, Part: gem.Parts{part}})
+			continue
+		}
+		parts := gem.Parts{}
+		if mess.Content != "" || len(mess.ToolCalls) == 0 {
+			parts = append(parts, gem.TextPart(mess.Content))
+		}
+		for _, tc := range mess.ToolCalls {
+			parts = append(parts, gem.FunctionCallPart(tc.Name, toolArguments(tc.Arguments)))
 		}
-		messages = append(messages, gem.Message{Role: messDict[mess.Role], Part: part})
+		messages = append(messages, gem.Message{Role: messDict[mess.Role], Part: parts})
 	}
 	var system map[string](map[string]string)
 	if systemMessage := cr.System.Content; systemMessage != "" {
@@ -106,8 +128,16 @@ func (cr CompletionRequest) ToOllama() ll.CompletionRequest {
 		systemMessage := ll.Message{Role: "system", Content: system}
 		messages = append(messages, systemMessage)
 	}
+	names := toolCallNames(cr.Messages)
 	for _, mess := range cr.Messages {
-		messages = append(messages, ll.Message{Role: mess.Role, Content: mess.Content})
+		ollamaMess := ll.Message{Role: mess.Role, Content: mess.Content}
+		if mess.Role == "tool" {
+			ollamaMess.ToolName = names[mess.ToolCallId]
+		}
+		for _, tc := range mess.ToolCalls {
+			ollamaMess.ToolCalls = append(ollamaMess.ToolCalls, ll.NewToolCall(tc.Id, tc.Name, toolArguments(tc.Arguments)))
+		}
+		messages = append(messages, ollamaMess)
 	}
 	request := ll.CompletionRequest{
 		Model:    cr.Model,
@@ -132,6 +162,146 @@ func (cr CompletionRequest) ToOllama() ll.CompletionRequest {
 	return request
 }
 
+// toolCallNames maps the ids of the tool calls in messages to the names of
+// the tools. Gemini and Ollama match the results to the calls by name.
+func toolCallNames(messages []m.Message) map[string]string {
+	names := map[string]string{}
+	for _, mess := range messages {
+		for _, tc := range mess.ToolCalls {
+			names[tc.Id] = tc.Name
+		}
+	}
+	return names
+}
+
+// toolArguments are the arguments of a tool call as a JSON object, as
+// Gemini and Ollama expect them.
+func toolArguments(arguments json.RawMessage) json.RawMessage {
+	if len(bytes.TrimSpace(arguments)) == 0 {
+		return json.RawMessage("{}")
+	}
+	return arguments
+}
+
+func isFunctionResponse(message gem.Message) bool {
+	if len(message.Part) == 0 {
+		return false
+	}
+	_, ok := message.Part[0]["functionResponse"]
+	return ok
+}
+
+func (cr CompletionRequest) ToBedrock() br.CompletionRequest {
+	messages := []br.Message{}
+	for _, mess := range cr.Messages {
+		role := mess.Role
+		content := []br.ContentBlock{}
+		if mess.Role == "tool" {
+			// tool results are sent by the user
+			role = br.User
+			content = append(content, br.NewToolResult(mess.ToolCallId, mess.Content))
+		} else if mess.Content != "" {
+			content = append(content, br.ContentBlock{Text: mess.Content})
+		}
+		for _, tc := range mess.ToolCalls {
+			toolUse := &br.ToolUse{ToolUseId: tc.Id, Name: tc.Name, Input: toolArguments(tc.Arguments)}
+			content = append(content, br.ContentBlock{ToolUse: toolUse})
+		}
+		// roles must alternate, so the results of tools called together
+		// and the user message after them form a single message
+		if last := len(messages) - 1; last >= 0 && messages[last].Role == role {
+			messages[last].Content = append(messages[last].Content, content...)
+			continue
+		}
+		messages = append(messages, br.Message{Role: role, Content: content})
+	}
+	var system []br.SystemMessage
+	if systemMessage := cr.System.Content; systemMessage != "" {
+		system = []br.SystemMessage{{Text: systemMessage}}
+	}
+
+	var config *br.InferenceConfig
+	if cr.MaxCompletionTokens != nil || cr.Temperature != nil || cr.TopP != nil || len(cr.Stop) > 0 {
+		config = &br.InferenceConfig{
+			MaxTokens:     cr.MaxCompletionTokens,
+			Temperature:   cr.Temperature,
+			TopP:          cr.TopP,
+			StopSequences: cr.Stop,
+		}
+	}
+
+	additional := bedrockModelFields(cr)
+
+	var toolConfig *br.ToolConfig
+	if len(cr.Tools) > 0 {
+		toolConfig = &br.ToolConfig{}
+		for _, t := range cr.Tools {
+			toolConfig.Tools = append(toolConfig.Tools, t.ToBedrock())
+		}
+	}
+
+	request := br.CompletionRequest{
+		Model:                        cr.Model,
+		Messages:                     messages,
+		System:                       system,
+		InferenceConfig:              config,
+		ToolConfig:                   toolConfig,
+		Stream:                       cr.Stream,
+		AdditionalModelRequestFields: additional,
+		Ctx:                          cr.Ctx,
+	}
+
+	return request
+}
+
+// bedrockModelFields returns the parameters of cr that the Converse API
+// does not define, under the names of the model family of cr.Model. The
+// models reject fields they do not know, so parameters the family does not
+// accept are dropped: top_k is sent to Anthropic, Cohere and Amazon Nova
+// models, the penalties and seed to Cohere models only.
+func bedrockModelFields(cr CompletionRequest) map[string]any {
+	fields := map[string]any{}
+	switch bedrockFamily(cr.Model) {
+	case "anthropic":
+		if cr.TopK != nil {
+			fields["top_k"] = *cr.TopK
+		}
+	case "cohere":
+		if cr.TopK != nil {
+			fields["k"] = *cr.TopK
+		}
+		if cr.FreqPenalty != nil {
+			fields["frequency_penalty"] = *cr.FreqPenalty
+		}
+		if cr.PresencePenalty != nil {
+			fields["presence_penalty"] = *cr.PresencePenalty
+		}
+		if cr.Seed != nil {
+			fields["seed"] = *cr.Seed
+		}
+	case "amazon":
+		if cr.TopK != nil && strings.Contains(cr.Model, "nova") {
+			fields["inferenceConfig"] = map[string]any{"topK": *cr.TopK}
+		}
+	}
+	return fields
+}
+
+// bedrockFamily returns the provider of a Bedrock model id, e.g. anthropic
+// for anthropic.claude-3-haiku-20240307-v1:0, the inference profile
+// us.anthropic.claude-3-haiku-20240307-v1:0 or an ARN ending with either.
+func bedrockFamily(model string) string {
+	model = model[strings.LastIndex(model, "/")+1:]
+	parts := strings.SplitN(model, ".", 3)
+	if len(parts) == 3 {
+		switch parts[0] {
+		case "us", "eu", "apac", "us-gov", "global":
+			return parts[1]
+		}
+	}
+	return parts[0]
+}
+
 func ResponseFromGemini(response gem.CompletionResponse) CompletionResponse {
 	usage := CompletionUsage{
 		PromptTokens:     response.Usage.PromptTokens,
@@ -141,9 +311,16 @@ func ResponseFromGemini(response gem.CompletionResponse) CompletionResponse {
 
 	message := m.Message{}
 	finishReason := false
+	reason := ""
 	if len(response.Choices) != 0 {
 		c := response.Choices[0]
-		if content := c.Content.Part[0]["text"]; c.Content.Role == "user" {
+		content := ""
+		for _, part := range c.Content.Part {
+			if text, ok := part["text"].(string); ok {
+				content += text
+			}
+		}
+		if c.Content.Role == "user" {
 			message = m.UserMessage(content)
 		} else {
 			message = m.AssistantMessage(content)
@@ -152,7 +329,7 @@ func ResponseFromGemini(response gem.CompletionResponse) CompletionResponse {
 		if c.FinishReason != "" {
 			finishReason = true
 		}
-
+		reason = c.FinishReason
 	}
 
 	var compErr CompletionError
@@ -163,12 +340,14 @@ func ResponseFromGemini(response gem.CompletionResponse) CompletionResponse {
 		}
 	}
 	converted := CompletionResponse{
-		Model:      response.Model,
-		Message:    message,
-		Done:       finishReason,
-		Usage:      usage,
-		Error:      compErr,
-		StatusCode: response.StatusCode,
+		Model:        response.Model,
+		Message:      message,
+		Done:         finishReason,
+		FinishReason: reason,
+		Usage:        usage,
+		Error:        compErr,
+		StatusCode:   response.StatusCode,
+		Header:       response.Header,
 	}
 
 	return converted
@@ -184,6 +363,7 @@ func ResponseFromOpenAI(response oai.CompletionResponse, streaming bool) Complet
 
 	message := m.Message{}
 	finishReason := false
+	reason := ""
 	completionType := Text
 	if len(response.Choices) != 0 {
 		c := response.Choices[0]
@@ -221,6 +401,9 @@ func ResponseFromOpenAI(response oai.CompletionResponse, streaming bool) Complet
 					Name:      otc.Function.Name,
 					Arguments: json.RawMessage(otc.Function.Arguments),
 				}
+				if streaming {
+					tc.Index = otc.Index
+				}
 				toolCalls = append(toolCalls, tc)
 			}
 			message.ToolCalls = toolCalls
@@ -230,6 +413,7 @@ func ResponseFromOpenAI(response oai.CompletionResponse, streaming bool) Complet
 		if c.FinishReason != "" {
 			finishReason = true
 		}
+		reason = c.FinishReason
 	}
 
 	var compErr CompletionError
@@ -237,19 +421,22 @@ func ResponseFromOpenAI(response oai.CompletionResponse, streaming bool) Complet
 		compErr = CompletionError{
 			Message: response.Error.Message,
 			Type:    response.Error.Type,
+			Code:    response.Error.Code,
 		}
 	}
 	converted := CompletionResponse{
-		Id:         response.Id,
-		Object:     response.Object,
-		Created:    response.Created,
-		Model:      response.Model,
-		Message:    message,
-		Done:       finishReason,
-		Usage:      usage,
-		Error:      compErr,
-		StatusCode: response.StatusCode,
-		Type:       completionType,
+		Id:           response.Id,
+		Object:       response.Object,
+		Created:      response.Created,
+		Model:        response.Model,
+		Message:      message,
+		Done:         finishReason,
+		FinishReason: reason,
+		Usage:        usage,
+		Error:        compErr,
+		StatusCode:   response.StatusCode,
+		Header:       response.Header,
+		Type:         completionType,
 	}
 
 	return converted
@@ -291,13 +478,71 @@ func ResponseFromOllama(response ll.CompletionResponse) CompletionResponse {
 		}
 	}
 	converted := CompletionResponse{
-		Created:    int(created.Unix()),
-		Model:      response.Model,
-		Message:    message,
-		Done:       finishReason,
-		Usage:      usage,
-		Error:      compErr,
-		StatusCode: response.StatusCode,
+		Created:      int(created.Unix()),
+		Model:        response.Model,
+		Message:      message,
+		Done:         finishReason,
+		FinishReason: response.DoneReason,
+		Usage:        usage,
+		Error:        compErr,
+		StatusCode:   response.StatusCode,
+		Header:       response.Header,
+	}
+
+	return converted
+}
+
+func ResponseFromBedrock(response br.CompletionResponse) CompletionResponse {
+	usage := CompletionUsage{
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	}
+
+	message := m.Message{}
+	completionType := Text
+	if output := response.Output.Message; len(output.Content) != 0 {
+		content := new(strings.Builder)
+		toolCalls := []m.ToolCall{}
+		for _, block := range output.Content {
+			content.WriteString(block.Text)
+			if tu := block.ToolUse; tu != nil {
+				tc := m.ToolCall{
+					Id:        tu.ToolUseId,
+					Type:      "function",
+					Name:      tu.Name,
+					Arguments: tu.Input,
+				}
+				toolCalls = append(toolCalls, tc)
+			}
+		}
+		if output.Role == "user" {
+			message = m.UserMessage(content.String())
+		} else {
+			message = m.AssistantMessage(content.String())
+		}
+		if len(toolCalls) > 0 {
+			message.ToolCalls = toolCalls
+			completionType = ToolCall
+		}
+	}
+
+	var compErr CompletionError
+	if response.Error.Type != "" || response.Error.Message != "" {
+		compErr = CompletionError{
+			Message: response.Error.Message,
+			Type:    response.Error.Type,
+		}
+	}
+	converted := CompletionResponse{
+		Message:      message,
+		Done:         response.StopReason != "",
+		FinishReason: response.StopReason,
+		Usage:        usage,
+		Error:        compErr,
+		StatusCode:   response.StatusCode,
+		Header:       response.Header,
+		Type:         completionType,
 	}
 
 	return converted
@@ -318,7 +563,7 @@ func openaiComplete(request *CompletionRequest, c LLMClient) (CompletionRequest,
 	}
 	_, result, err := openaiClient.Complete(&openaiReq)
 	if err != nil {
-		return *request, CompletionResponse{}, err
+		return *request, ResponseFromOpenAI(result, c.stream), errorFromOpenAI(err)
 	}
 
 	return *request, ResponseFromOpenAI(result, c.stream), nil
@@ -339,7 +584,7 @@ func geminiComplete(request *CompletionRequest, c LLMClient) (CompletionRequest,
 	}
 	_, result, err := geminiClient.Complete(&geminiReq)
 	if err != nil {
-		return *request, CompletionResponse{}, err
+		return *request, ResponseFromGemini(result), errorFromGemini(err)
 	}
 
 	return *request, ResponseFromGemini(result), nil
@@ -360,8 +605,32 @@ func ollamaComplete(request *CompletionRequest, c LLMClient) (CompletionRequest,
 	}
 	_, result, err := ollamaClient.Complete(&ollamaReq)
 	if err != nil {
-		return *request, CompletionResponse{}, err
+		return *request, ResponseFromOllama(result), errorFromOllama(err)
 	}
 
 	return *request, ResponseFromOllama(result), nil
 }
+
+func bedrockComplete(request *CompletionRequest, c LLMClient) (CompletionRequest, CompletionResponse, error) {
+	bedrockReq := request.ToBedrock()
+	bedrockClient, err := c.ToBedrock()
+	if err != nil {
+		return *request, CompletionResponse{}, err
+	}
+	if c.stream {
+		streamFunc := func(bedrockRes br.CompletionResponse) error {
+			res := ResponseFromBedrock(bedrockRes)
+			return c.streamFunction(res)
+		}
+		bedrockClient.EnableStream(streamFunc)
+	}
+	_, result, err := bedrockClient.Complete(&bedrockReq)
+	if err != nil {
+		return *request, ResponseFromBedrock(result), errorFromBedrock(err)
+	}
+
+	res := ResponseFromBedrock(result)
+	res.Model = request.Model
+
+	return *request, res, nil
+}
@@ -0,0 +1,62 @@
+package gollum
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	m "github.com/azr4e1/gollum/message"
+)
+
+func TestToBedrockModelFields(t *testing.T) {
+	topK, seed := 40, 7
+	penalty := 0.5
+	tests := []struct {
+		model string
+		want  map[string]any
+	}{
+		{"anthropic.claude-3-haiku-20240307-v1:0", map[string]any{"top_k": 40}},
+		{"us.anthropic.claude-3-5-sonnet-20240620-v1:0", map[string]any{"top_k": 40}},
+		{"arn:aws:bedrock:us-east-1:123456789012:inference-profile/eu.anthropic.claude-3-haiku-20240307-v1:0", map[string]any{"top_k": 40}},
+		{"cohere.command-r-v1:0", map[string]any{"k": 40, "frequency_penalty": 0.5, "presence_penalty": 0.5, "seed": 7}},
+		{"amazon.nova-lite-v1:0", map[string]any{"inferenceConfig": map[string]any{"topK": 40}}},
+		{"meta.llama3-1-8b-instruct-v1:0", map[string]any{}},
+	}
+	for _, tt := range tests {
+		request := CompletionRequest{Model: tt.model, TopK: &topK, Seed: &seed, FreqPenalty: &penalty, PresencePenalty: &penalty}
+		got := request.ToBedrock().AdditionalModelRequestFields
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestToBedrockEmptyToolArguments(t *testing.T) {
+	call := m.AssistantMessage("")
+	call.ToolCalls = []m.ToolCall{{Id: "tooluse_1", Type: "function", Name: "time"}}
+	request := CompletionRequest{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Messages: []m.Message{m.UserMessage("What time is it?"), call},
+	}
+
+	body, err := json.Marshal(request.ToBedrock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := struct {
+		Messages []struct {
+			Content []struct {
+				ToolUse *struct {
+					Input json.RawMessage `json:"input"`
+				} `json:"toolUse"`
+			} `json:"content"`
+		} `json:"messages"`
+	}{}
+	if err := json.Unmarshal(body, &sent); err != nil {
+		t.Fatal(err)
+	}
+	toolUse := sent.Messages[1].Content[0].ToolUse
+	if toolUse == nil || string(toolUse.Input) != "{}" {
+		t.Errorf("request = %s", body)
+	}
+}
@@ -0,0 +1,47 @@
+package gollum
+
+import (
+	"errors"
+	"strconv"
+
+	br "github.com/azr4e1/gollum/bedrock"
+	gem "github.com/azr4e1/gollum/gemini"
+	ll "github.com/azr4e1/gollum/ollama"
+	oai "github.com/azr4e1/gollum/openai"
+)
+
+func errorFromOpenAI(err error) error {
+	var apiErr *oai.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	return newAPIError(OPENAI.String(), apiErr.StatusCode, apiErr.Code, apiErr.Type, apiErr.Message, apiErr.Header, apiErr.Body)
+}
+
+func errorFromGemini(err error) error {
+	var apiErr *gem.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	code := ""
+	if apiErr.Code != 0 {
+		code = strconv.Itoa(apiErr.Code)
+	}
+	return newAPIError(GEMINI.String(), apiErr.StatusCode, code, apiErr.Status, apiErr.Message, apiErr.Header, apiErr.Body)
+}
+
+func errorFromOllama(err error) error {
+	var apiErr *ll.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	return newAPIError(OLLAMA.String(), apiErr.StatusCode, "", "", apiErr.Message, apiErr.Header, apiErr.Body)
+}
+
+func errorFromBedrock(err error) error {
+	var apiErr *br.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	return newAPIError(BEDROCK.String(), apiErr.StatusCode, "", apiErr.Type, apiErr.Message, apiErr.Header, apiErr.Body)
+}
@@ -0,0 +1,165 @@
+package gollum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/azr4e1/gollum/retry"
+)
+
+// Sentinel errors describing the class of a provider failure. An *APIError
+// matches them through errors.Is.
+var (
+	ErrRateLimited     = errors.New("rate limited")
+	ErrAuth            = errors.New("authentication failed")
+	ErrContextLength   = errors.New("context length exceeded")
+	ErrContentFiltered = errors.New("content filtered")
+	ErrModelNotFound   = errors.New("model not found")
+)
+
+// ErrBudgetExceeded is returned, without contacting the provider, once the
+// client or context budget has been spent.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ErrCircuitOpen is returned, without contacting the provider, while the
+// circuit breaker of the client is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// APIError is the provider independent error returned when an API replies
+// with an error.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Code       string
+	Type       string
+	Message    string
+	RequestID  string
+	RetryAfter time.Duration
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	parts := []string{}
+	for _, p := range []string{e.Provider, e.Type, e.Message} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("request failed with status %d", e.StatusCode)
+	}
+	return strings.Join(parts, ": ")
+}
+
+func (e *APIError) Is(target error) bool {
+	return target != nil && e.class() == target
+}
+
+// class maps status codes and the provider specific error codes onto one of
+// the sentinel errors.
+func (e *APIError) class() error {
+	code := strings.ToLower(e.Code + " " + e.Type)
+	message := strings.ToLower(e.Message)
+
+	switch {
+	case containsAny(code, "context_length_exceeded", "string_above_max_length") ||
+		containsAny(message, "context length", "context window", "maximum context", "too many tokens", "input is too long", "prompt is too long", "exceeds the maximum number of tokens"):
+		return ErrContextLength
+	case containsAny(code, "content_filter", "content_policy_violation", "safety", "guardrail"):
+		return ErrContentFiltered
+	case e.StatusCode == http.StatusTooManyRequests ||
+		containsAny(code, "rate_limit", "resource_exhausted", "throttling", "too_many_requests"):
+		return ErrRateLimited
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+		containsAny(code, "invalid_api_key", "authentication", "unauthenticated", "permission_denied", "accessdenied", "unrecognizedclient", "invalidsignature") ||
+		containsAny(message, "api key not valid", "incorrect api key", "invalid api key"):
+		return ErrAuth
+	case containsAny(code, "model_not_found", "resourcenotfound") ||
+		(e.StatusCode == http.StatusNotFound && strings.Contains(message, "model")):
+		return ErrModelNotFound
+	}
+
+	return nil
+}
+
+// ErrorType returns a short, low cardinality name for the class of err,
+// suitable as a metric label.
+func ErrorType(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrAuth):
+		return "auth"
+	case errors.Is(err, ErrContextLength):
+		return "context_length"
+	case errors.Is(err, ErrContentFiltered):
+		return "content_filtered"
+	case errors.Is(err, ErrModelNotFound):
+		return "model_not_found"
+	case errors.Is(err, ErrBudgetExceeded):
+		return "budget_exceeded"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode >= http.StatusInternalServerError {
+			return "server_error"
+		}
+		return "api_error"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+
+	return "other"
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func newAPIError(provider string, statusCode int, code, errType, message string, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Code:       code,
+		Type:       errType,
+		Message:    message,
+		Header:     header,
+		Body:       body,
+	}
+	if header != nil {
+		for _, name := range []string{"X-Request-Id", "X-Amzn-Requestid", "Request-Id", "X-Goog-Request-Id"} {
+			if id := header.Get(name); id != "" {
+				apiErr.RequestID = id
+				break
+			}
+		}
+		apiErr.RetryAfter, _ = retry.ServerDelay(header)
+	}
+
+	return apiErr
+}
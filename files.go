@@ -0,0 +1,113 @@
+package gollum
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	oai "github.com/azr4e1/gollum/openai"
+)
+
+// FileInfo describes a file uploaded to the provider, e.g. for batches or
+// fine-tuning.
+type FileInfo struct {
+	ID      string
+	Name    string
+	Bytes   int64
+	Purpose string
+	Created time.Time
+	// Expires is zero for files that do not expire.
+	Expires time.Time
+}
+
+func fileFromOpenAI(file oai.File) FileInfo {
+	info := FileInfo{
+		ID:      file.Id,
+		Name:    file.Filename,
+		Bytes:   file.Bytes,
+		Purpose: file.Purpose,
+	}
+	if file.CreatedAt != 0 {
+		info.Created = time.Unix(file.CreatedAt, 0)
+	}
+	if file.ExpiresAt != 0 {
+		info.Expires = time.Unix(file.ExpiresAt, 0)
+	}
+	return info
+}
+
+func (c LLMClient) filesClient() (oai.OpenaiClient, error) {
+	if c.provider != OPENAI {
+		return oai.OpenaiClient{}, errors.New("files not implemented for this provider.")
+	}
+	return c.ToOpenAI()
+}
+
+// UploadFile streams the content of r to the provider as a file named
+// name. purpose is what the file is for, e.g. "batch", "fine-tune",
+// "assistants", "vision" or "user_data" with OpenAI. The client timeout does
+// not apply to the upload, which ctx cancels.
+func (c LLMClient) UploadFile(ctx context.Context, name, purpose string, r io.Reader) (FileInfo, error) {
+	client, err := c.filesClient()
+	if err != nil {
+		return FileInfo{}, err
+	}
+	file, err := client.UploadFile(ctx, name, purpose, r)
+	if err != nil {
+		return FileInfo{}, errorFromOpenAI(err)
+	}
+	return fileFromOpenAI(file), nil
+}
+
+// ListFiles returns the uploaded files with purpose, or all of them when
+// purpose is empty.
+func (c LLMClient) ListFiles(ctx context.Context, purpose string) ([]FileInfo, error) {
+	client, err := c.filesClient()
+	if err != nil {
+		return nil, err
+	}
+	files, err := client.ListFiles(ctx, purpose)
+	if err != nil {
+		return nil, errorFromOpenAI(err)
+	}
+	infos := make([]FileInfo, len(files))
+	for i, file := range files {
+		infos[i] = fileFromOpenAI(file)
+	}
+	return infos, nil
+}
+
+func (c LLMClient) RetrieveFile(ctx context.Context, id string) (FileInfo, error) {
+	client, err := c.filesClient()
+	if err != nil {
+		return FileInfo{}, err
+	}
+	file, err := client.RetrieveFile(ctx, id)
+	if err != nil {
+		return FileInfo{}, errorFromOpenAI(err)
+	}
+	return fileFromOpenAI(file), nil
+}
+
+func (c LLMClient) DeleteFile(ctx context.Context, id string) error {
+	client, err := c.filesClient()
+	if err != nil {
+		return err
+	}
+	return errorFromOpenAI(client.DeleteFile(ctx, id))
+}
+
+// FileContent returns the content of file id. The caller closes it. As for
+// uploads, the client timeout does not apply.
+func (c LLMClient) FileContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	client, err := c.filesClient()
+	if err != nil {
+		return nil, err
+	}
+	content, err := client.FileContent(ctx, id)
+	if err != nil {
+		return nil, errorFromOpenAI(err)
+	}
+	return content, nil
+}
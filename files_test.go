@@ -0,0 +1,93 @@
+package gollum
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redirect sends every request to a test server.
+type redirect struct{ target *url.URL }
+
+func (rd redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rd.target.Scheme
+	req.URL.Host = rd.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// slowReader returns its content one byte at a time, after delay.
+type slowReader struct {
+	content string
+	delay   time.Duration
+}
+
+func (sr *slowReader) Read(p []byte) (int, error) {
+	if sr.content == "" {
+		return 0, io.EOF
+	}
+	time.Sleep(sr.delay)
+	n := copy(p[:1], sr.content)
+	sr.content = sr.content[n:]
+	return n, nil
+}
+
+// Uploads and downloads outlasting the client timeout are not cut short.
+func TestFileTransfersIgnoreTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"id":"file-1","object":"file","bytes":5,"filename":"batch.jsonl","purpose":"batch"}`)
+		case strings.HasSuffix(r.URL.Path, "/content"):
+			w.(http.Flusher).Flush()
+			for _, c := range "hello" {
+				time.Sleep(20 * time.Millisecond)
+				fmt.Fprintf(w, "%c", c)
+				w.(http.Flusher).Flush()
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	client, err := NewClient(WithProvider(OPENAI), WithAPIKey("sk-test"), WithTransport(redirect{target}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Timeout = 50 * time.Millisecond
+
+	file, err := client.UploadFile(context.Background(), "batch.jsonl", "batch", &slowReader{content: "hello", delay: 20 * time.Millisecond})
+	if err != nil || file.ID != "file-1" {
+		t.Fatalf("upload: %+v, %v", file, err)
+	}
+
+	content, err := client.FileContent(context.Background(), "file-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+	body, err := io.ReadAll(content)
+	if err != nil || string(body) != "hello" {
+		t.Errorf("content %q, error %v", body, err)
+	}
+
+	// the context still ends a transfer
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err = client.UploadFile(ctx, "batch.jsonl", "batch", &slowReader{content: "hello", delay: 20 * time.Millisecond})
+	if err == nil {
+		t.Error("expected the upload to be canceled")
+	}
+}
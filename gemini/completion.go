@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	// m "github.com/azr4e1/gollum/message"
 	"net/http"
@@ -67,13 +66,22 @@ type CompletionResponse struct {
 	Usage      CompletionUsage    `json:"usageMetadata"`
 	Error      CompletionError    `json:"error,omitempty"`
 	StatusCode int                `json:"status_code"`
+	Header     http.Header        `json:"-"`
+	body       []byte
 }
 
 func (or CompletionResponse) err() error {
-	if or.Error.Status == "" && or.Error.Message == "" {
+	if or.Error.Status == "" && or.Error.Message == "" && or.StatusCode < http.StatusBadRequest {
 		return nil
 	}
-	return errors.New(fmt.Sprintf("%s: %s", or.Error.Status, or.Error.Message))
+	return &APIError{
+		StatusCode: or.StatusCode,
+		Status:     or.Error.Status,
+		Code:       or.Error.Code,
+		Message:    or.Error.Message,
+		Header:     or.Header,
+		Body:       or.body,
+	}
 }
 
 func makeHTTPCompletionRequest(request *CompletionRequest, oc GeminiClient) (*http.Response, error) {
@@ -97,8 +105,7 @@ func makeHTTPCompletionRequest(request *CompletionRequest, oc GeminiClient) (*ht
 		req = req.WithContext(request.Ctx)
 	}
 
-	client := http.Client{Timeout: oc.Timeout}
-	res, err := client.Do(req)
+	res, err := oc.Retry.Do(oc.httpClient(), req)
 
 	return res, err
 }
@@ -0,0 +1,34 @@
+package gemini
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIError is returned when Gemini replies with an error payload or with a
+// non successful status code.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Code       int
+	Message    string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// statusError is used when an unsuccessful reply carries no error payload
+// that could be decoded.
+func statusError(res *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Status:     http.StatusText(res.StatusCode),
+		Code:       res.StatusCode,
+		Message:    string(body),
+		Header:     res.Header,
+		Body:       body,
+	}
+}
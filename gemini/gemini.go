@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/azr4e1/gollum/retry"
 )
 
 const (
@@ -26,6 +28,10 @@ type GeminiClient struct {
 	stream         bool
 	streamFunction StreamingFunction
 	Timeout        time.Duration
+	Retry          retry.Policy
+	// HTTPClient is used to send requests when set. Otherwise a client
+	// with Timeout and the default transport is used.
+	HTTPClient *http.Client
 }
 
 func NewClient(apiKey string) (GeminiClient, error) {
@@ -35,6 +41,13 @@ func NewClient(apiKey string) (GeminiClient, error) {
 	return GeminiClient{apiKey: apiKey, Timeout: 30 * time.Second}, nil
 }
 
+func (oc GeminiClient) httpClient() *http.Client {
+	if oc.HTTPClient != nil {
+		return oc.HTTPClient
+	}
+	return &http.Client{Timeout: oc.Timeout}
+}
+
 func (oc *GeminiClient) EnableStream(function StreamingFunction) {
 	oc.stream = true
 	oc.streamFunction = function
@@ -66,18 +79,23 @@ func (oc GeminiClient) readCompletionResponse(res *http.Response) (CompletionRes
 	}
 
 	// remove data prefix from response
-	if string(body)[:len(dataPrefix)] == dataPrefix {
+	if bytes.HasPrefix(body, []byte(dataPrefix)) {
 		body = body[len([]byte(dataPrefix)):]
 	}
 
 	geminiRes := new(CompletionResponse)
 	err = json.Unmarshal(body, geminiRes)
 	if err != nil {
+		if res.StatusCode >= http.StatusBadRequest {
+			return CompletionResponse{StatusCode: res.StatusCode}, statusError(res, body)
+		}
 		return CompletionResponse{}, err
 	}
 
 	// attach status code to response object
 	geminiRes.StatusCode = res.StatusCode
+	geminiRes.Header = res.Header
+	geminiRes.body = body
 
 	return *geminiRes, geminiRes.err()
 }
@@ -118,6 +136,7 @@ func (oc GeminiClient) readCompletionStreamResponse(res *http.Response) error {
 		}
 		// attach status code to response object
 		chunk.StatusCode = res.StatusCode
+		chunk.Header = res.Header
 
 		err = oc.streamFunction(*chunk)
 		if err != nil {
@@ -133,11 +152,13 @@ func (oc GeminiClient) readCompletionStreamResponse(res *http.Response) error {
 	geminiRes := new(CompletionResponse)
 	err = json.Unmarshal(body, geminiRes)
 	if err != nil {
-		return err
+		return statusError(res, body)
 	}
 
 	// attach status code to response object
 	geminiRes.StatusCode = res.StatusCode
+	geminiRes.Header = res.Header
+	geminiRes.body = body
 
 	return geminiRes.err()
 }
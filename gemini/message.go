@@ -1,15 +1,34 @@
 package gemini
 
+import "encoding/json"
+
 const (
 	System    = "system"
 	Assistant = "model"
 	User      = "user"
 )
 
-type Parts [](map[string]string)
+// Parts are the text, functionCall and functionResponse parts of a
+// message.
+type Parts [](map[string]any)
 
 type Message struct {
 	Role string `json:"role"`
 	Part Parts  `json:"parts"`
-	// ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+func TextPart(text string) map[string]any {
+	return map[string]any{"text": text}
+}
+
+// FunctionCallPart is a call of the function name by the model. args is a
+// JSON object.
+func FunctionCallPart(name string, args json.RawMessage) map[string]any {
+	return map[string]any{"functionCall": map[string]any{"name": name, "args": args}}
+}
+
+// FunctionResponsePart is the output of a call of the function name.
+func FunctionResponsePart(name, output string) map[string]any {
+	response := map[string]any{"content": output}
+	return map[string]any{"functionResponse": map[string]any{"name": name, "response": response}}
 }
@@ -0,0 +1,204 @@
+// Package gollumtest helps testing code built on gollum without live API
+// keys, by recording provider exchanges to cassette files and replaying
+// them offline, or by scripting the replies of a mock client.
+package gollumtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const redacted = "[REDACTED]"
+
+var secretHeaders = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key", "X-Amz-Security-Token"}
+
+var secretParams = []string{"key", "api_key", "X-Amz-Security-Token", "X-Amz-Signature", "X-Amz-Credential"}
+
+// Cassette is the list of HTTP exchanges recorded in a file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+	// Chunks holds the events of SSE and NDJSON streams, one per entry,
+	// instead of Body. They are served one at a time on replay.
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+// Body is stored as text when it is valid UTF-8, and base64 encoded
+// otherwise, e.g. for audio or AWS event streams.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*b = Body(text)
+		return nil
+	}
+	encoded := struct {
+		Base64 string `json:"base64"`
+	}{}
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return err
+	}
+	*b, err = base64.StdEncoding.DecodeString(encoded.Base64)
+	return err
+}
+
+// LoadCassette reads a cassette file. A missing file is an empty cassette.
+func LoadCassette(path string) (Cassette, error) {
+	body, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Cassette{}, nil
+	}
+	if err != nil {
+		return Cassette{}, err
+	}
+
+	cassette := Cassette{}
+	err = json.Unmarshal(body, &cassette)
+	return cassette, err
+}
+
+func (c Cassette) Save(path string) error {
+	body, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(body, '\n'), 0o644)
+}
+
+// Scrubber removes secrets from recorded requests and responses. API key
+// headers and query parameters are always removed, Secrets lists other
+// values, such as the API key itself, replaced wherever they appear.
+type Scrubber struct {
+	Secrets []string
+}
+
+func (s Scrubber) text(text string) string {
+	for _, secret := range s.Secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, redacted)
+		}
+	}
+	return text
+}
+
+func (s Scrubber) url(u *url.URL) string {
+	scrubbed := *u
+	query := scrubbed.Query()
+	for _, name := range secretParams {
+		if query.Has(name) {
+			query.Set(name, redacted)
+		}
+	}
+	scrubbed.RawQuery = query.Encode()
+	return s.text(scrubbed.String())
+}
+
+func (s Scrubber) header(header http.Header) http.Header {
+	scrubbed := http.Header{}
+	for name, values := range header {
+		for _, value := range values {
+			scrubbed.Add(name, s.text(value))
+		}
+	}
+	for _, name := range secretHeaders {
+		if scrubbed.Get(name) != "" {
+			scrubbed.Set(name, redacted)
+		}
+	}
+	return scrubbed
+}
+
+func (s Scrubber) body(body []byte) Body {
+	if len(body) == 0 || !utf8.Valid(body) {
+		return body
+	}
+	return Body(s.text(string(body)))
+}
+
+// Matcher reports whether an outgoing request, already scrubbed, matches
+// a recorded one.
+type Matcher func(req Request, recorded Request) bool
+
+// MatchMethodURL matches requests on their method and URL.
+func MatchMethodURL(req Request, recorded Request) bool {
+	return req.Method == recorded.Method && req.URL == recorded.URL
+}
+
+// MatchMethodPath matches requests on their method and URL path, ignoring
+// the host and query, e.g. for test servers listening on random ports.
+func MatchMethodPath(req Request, recorded Request) bool {
+	if req.Method != recorded.Method {
+		return false
+	}
+	a, errA := url.Parse(req.URL)
+	b, errB := url.Parse(recorded.URL)
+	if errA != nil || errB != nil {
+		return req.URL == recorded.URL
+	}
+	return a.Path == b.Path
+}
+
+// MatchBody matches requests on their bodies, compared as JSON values when
+// both are JSON so that formatting and key order do not matter.
+func MatchBody(req Request, recorded Request) bool {
+	var a, b any
+	if json.Unmarshal(req.Body, &a) == nil && json.Unmarshal(recorded.Body, &b) == nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return bytes.Equal(req.Body, recorded.Body)
+}
+
+// MatchAll combines matchers, all of which must match.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(req Request, recorded Request) bool {
+		for _, match := range matchers {
+			if !match(req, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// DefaultMatcher matches requests on their method, URL and body.
+var DefaultMatcher = MatchAll(MatchMethodURL, MatchBody)
@@ -0,0 +1,265 @@
+package gollumtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	g "github.com/azr4e1/gollum"
+	m "github.com/azr4e1/gollum/message"
+)
+
+const mockProvider = "mock"
+
+// Reply is a scripted answer of a Mock.
+type Reply struct {
+	// Chunks are passed to the streaming function one by one, or joined
+	// into a single response when the client does not stream.
+	Chunks []g.CompletionResponse
+	// Delay is waited before the response, and before every chunk.
+	Delay time.Duration
+	Err   error
+	// Check is called with the request before replying. When it returns
+	// an error, the completion fails with it.
+	Check func(g.CompletionRequest) error
+}
+
+// Text replies with a fixed assistant message.
+func Text(content string) Reply {
+	return Reply{Chunks: []g.CompletionResponse{{
+		Type:    g.Text,
+		Message: m.AssistantMessage(content),
+	}}}
+}
+
+// ToolCalls replies with an assistant message calling tools.
+func ToolCalls(calls ...m.ToolCall) Reply {
+	message := m.AssistantMessage("")
+	message.ToolCalls = calls
+	return Reply{Chunks: []g.CompletionResponse{{
+		Type:         g.ToolCall,
+		Message:      message,
+		FinishReason: "tool_calls",
+	}}}
+}
+
+// Stream replies with one chunk per part, waiting delay before each.
+func Stream(delay time.Duration, parts ...string) Reply {
+	chunks := make([]g.CompletionResponse, len(parts))
+	for i, part := range parts {
+		chunks[i] = g.CompletionResponse{Type: g.Text, Message: m.AssistantMessage(part)}
+	}
+	return Reply{Chunks: chunks, Delay: delay}
+}
+
+// Error fails the completion with an *gollum.APIError, which matches the
+// gollum sentinel errors like a provider error would.
+func Error(statusCode int, code, message string) Reply {
+	return Reply{Err: &g.APIError{
+		Provider:   mockProvider,
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    message,
+		Header:     http.Header{},
+	}}
+}
+
+// Expect returns a copy of the reply that checks the request first.
+func (r Reply) Expect(check func(g.CompletionRequest) error) Reply {
+	r.Check = check
+	return r
+}
+
+// Mock answers completions with scripted replies, in order, and records
+// the requests it receives. It is safe for concurrent use.
+type Mock struct {
+	mu       sync.Mutex
+	replies  []Reply
+	requests []g.CompletionRequest
+}
+
+func NewMock(replies ...Reply) *Mock {
+	return &Mock{replies: replies}
+}
+
+// ErrOffline is returned by the requests of a mock client that the mock
+// does not answer, e.g. text to speech, batches and files.
+var ErrOffline = errors.New("gollumtest: network access is disabled for mock clients")
+
+// offline refuses every request, so that a mock client never reaches the
+// real provider.
+type offline struct{}
+
+func (offline) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, ErrOffline
+}
+
+// NewClient returns a client answered by mock. Application code can use it
+// like any other client, no request leaves the process: the requests the
+// mock does not answer fail with ErrOffline.
+func NewClient(mock *Mock) (g.LLMClient, error) {
+	if mock == nil {
+		return g.LLMClient{}, errors.New("mock cannot be nil.")
+	}
+	return g.NewClient(
+		g.WithProvider(g.OPENAI),
+		g.WithAPIKey(mockProvider),
+		g.WithTransport(offline{}),
+		g.WithMiddleware(mock.Middleware()),
+	)
+}
+
+// Add appends replies to the script.
+func (mk *Mock) Add(replies ...Reply) {
+	mk.mu.Lock()
+	defer mk.mu.Unlock()
+	mk.replies = append(mk.replies, replies...)
+}
+
+// Requests returns the requests received so far.
+func (mk *Mock) Requests() []g.CompletionRequest {
+	mk.mu.Lock()
+	defer mk.mu.Unlock()
+	return append([]g.CompletionRequest(nil), mk.requests...)
+}
+
+func (mk *Mock) LastRequest() (g.CompletionRequest, bool) {
+	mk.mu.Lock()
+	defer mk.mu.Unlock()
+	if len(mk.requests) == 0 {
+		return g.CompletionRequest{}, false
+	}
+	return mk.requests[len(mk.requests)-1], true
+}
+
+// Remaining returns the number of replies not used yet.
+func (mk *Mock) Remaining() int {
+	mk.mu.Lock()
+	defer mk.mu.Unlock()
+	return len(mk.replies)
+}
+
+// AssertCalls fails t unless exactly n completions were requested.
+func (mk *Mock) AssertCalls(t testing.TB, n int) {
+	t.Helper()
+	if calls := len(mk.Requests()); calls != n {
+		t.Errorf("expected %d completions, got %d", n, calls)
+	}
+}
+
+// AssertDone fails t when scripted replies were not used.
+func (mk *Mock) AssertDone(t testing.TB) {
+	t.Helper()
+	if remaining := mk.Remaining(); remaining > 0 {
+		t.Errorf("%d scripted replies were not used", remaining)
+	}
+}
+
+func (mk *Mock) next(request g.CompletionRequest) (Reply, error) {
+	mk.mu.Lock()
+	defer mk.mu.Unlock()
+
+	mk.requests = append(mk.requests, request)
+	if len(mk.replies) == 0 {
+		return Reply{}, fmt.Errorf("gollumtest: no scripted reply left for request %d", len(mk.requests))
+	}
+	reply := mk.replies[0]
+	mk.replies = mk.replies[1:]
+	return reply, nil
+}
+
+// Middleware answers every completion from the script without calling the
+// rest of the chain.
+func (mk *Mock) Middleware() g.Middleware {
+	return func(next g.CompleteFunc) g.CompleteFunc {
+		return func(request g.CompletionRequest, stream g.StreamingFunction) (g.CompletionResponse, error) {
+			reply, err := mk.next(request)
+			if err != nil {
+				return g.CompletionResponse{}, err
+			}
+			if reply.Check != nil {
+				err = reply.Check(request)
+				if err != nil {
+					return g.CompletionResponse{}, err
+				}
+			}
+
+			ctx := request.Ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if reply.Err != nil {
+				err = wait(ctx, reply.Delay)
+				if err != nil {
+					return g.CompletionResponse{}, err
+				}
+				return g.CompletionResponse{}, reply.Err
+			}
+
+			chunks := make([]g.CompletionResponse, len(reply.Chunks))
+			for i, chunk := range reply.Chunks {
+				chunks[i] = complete(chunk, request, i == len(reply.Chunks)-1)
+			}
+
+			if stream == nil {
+				err = wait(ctx, reply.Delay)
+				if err != nil {
+					return g.CompletionResponse{}, err
+				}
+				return g.JoinChunks(chunks), nil
+			}
+			for _, chunk := range chunks {
+				err = wait(ctx, reply.Delay)
+				if err != nil {
+					return g.CompletionResponse{}, err
+				}
+				err = stream(chunk)
+				if err != nil {
+					return g.CompletionResponse{}, err
+				}
+			}
+			return g.CompletionResponse{}, nil
+		}
+	}
+}
+
+// complete fills the fields a provider would set.
+func complete(chunk g.CompletionResponse, request g.CompletionRequest, last bool) g.CompletionResponse {
+	if chunk.Model == "" {
+		chunk.Model = request.Model
+	}
+	if chunk.StatusCode == 0 {
+		chunk.StatusCode = http.StatusOK
+	}
+	if chunk.Provider == "" {
+		chunk.Provider = mockProvider
+	}
+	if last {
+		chunk.Done = true
+		if chunk.FinishReason == "" {
+			chunk.FinishReason = "stop"
+		}
+	}
+	return chunk
+}
+
+func wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
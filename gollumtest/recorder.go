@@ -0,0 +1,307 @@
+package gollumtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type Mode int
+
+const (
+	// Replay serves the recorded responses and fails on unknown requests.
+	Replay Mode = iota
+	// Record sends every request and records the exchange.
+	Record
+	// ReplayOrRecord serves recorded responses and records the requests
+	// that do not match any.
+	ReplayOrRecord
+)
+
+// Recorder is an http.RoundTripper recording exchanges to a cassette, or
+// replaying them. Use it with gollum.WithTransport, and call Save when done
+// recording.
+type Recorder struct {
+	Mode     Mode
+	Matcher  Matcher
+	Scrubber Scrubber
+	// Transport sends the requests that are recorded. It defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+	changed  bool
+}
+
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	if path == "" {
+		return nil, errors.New("cassette path is empty.")
+	}
+	cassette := Cassette{}
+	if mode != Record {
+		var err error
+		cassette, err = LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Recorder{
+		Mode:     mode,
+		Matcher:  DefaultMatcher,
+		path:     path,
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+// NewTestRecorder creates a recorder for t using the cassette at path. It
+// records when the GOLLUM_RECORD environment variable is set and replays
+// otherwise, and saves the cassette when the test ends. The given secrets
+// are scrubbed from the recordings.
+func NewTestRecorder(t testing.TB, path string, secrets ...string) *Recorder {
+	t.Helper()
+
+	mode := Replay
+	if os.Getenv("GOLLUM_RECORD") != "" {
+		mode = Record
+	}
+	recorder, err := NewRecorder(path, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Scrubber.Secrets = secrets
+	t.Cleanup(func() {
+		err := recorder.Save()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return recorder
+}
+
+// Save writes the cassette when new exchanges were recorded.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.changed {
+		return nil
+	}
+	err := r.cassette.Save(r.path)
+	if err != nil {
+		return err
+	}
+	r.changed = false
+	return nil
+}
+
+func (r *Recorder) Cassette() Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := Request{
+		Method: req.Method,
+		URL:    r.Scrubber.url(req.URL),
+		Header: r.Scrubber.header(req.Header),
+		Body:   r.Scrubber.body(body),
+	}
+
+	if r.Mode != Record {
+		if interaction, ok := r.find(recorded); ok {
+			return replay(req, interaction.Response), nil
+		}
+		if r.Mode == Replay {
+			return nil, fmt.Errorf("gollumtest: no recorded interaction matches %s %s", recorded.Method, recorded.URL)
+		}
+	}
+
+	return r.record(req, recorded)
+}
+
+// find returns the first unused interaction matching req, or the last
+// used one so that repeated requests can be replayed.
+func (r *Recorder) find(req Request) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matcher := r.Matcher
+	if matcher == nil {
+		matcher = DefaultMatcher
+	}
+	reused := -1
+	for i, interaction := range r.cassette.Interactions {
+		if !matcher(req, interaction.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return interaction, true
+		}
+		reused = i
+	}
+	if reused >= 0 {
+		return r.cassette.Interactions[reused], true
+	}
+	return Interaction{}, false
+}
+
+func (r *Recorder) record(req *http.Request, recorded Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the exchange is added once the body has been consumed, so streams
+	// reach the caller as they arrive
+	res.Body = &recordingBody{
+		ReadCloser: res.Body,
+		done: func(body []byte) {
+			response := Response{
+				StatusCode: res.StatusCode,
+				Header:     r.Scrubber.header(res.Header),
+			}
+			if separator, ok := streamSeparator(res.Header); ok {
+				response.Chunks = splitChunks(r.Scrubber.text(string(body)), separator)
+			} else {
+				response.Body = r.Scrubber.body(body)
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: recorded, Response: response})
+			r.used = append(r.used, true)
+			r.changed = true
+		},
+	}
+
+	return res, nil
+}
+
+// streamSeparator returns the separator between the events of a streamed
+// response: blank lines for SSE and newlines for NDJSON.
+func streamSeparator(header http.Header) (string, bool) {
+	contentType := header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		return "\n\n", true
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+		return "\n", true
+	}
+	return "", false
+}
+
+// splitChunks splits body after every separator, keeping the separators so
+// that the chunks join back into the original body.
+func splitChunks(body string, separator string) []string {
+	chunks := []string{}
+	for body != "" {
+		i := strings.Index(body, separator)
+		if i < 0 {
+			chunks = append(chunks, body)
+			break
+		}
+		chunks = append(chunks, body[:i+len(separator)])
+		body = body[i+len(separator):]
+	}
+	return chunks
+}
+
+func replay(req *http.Request, recorded Response) *http.Response {
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	res := &http.Response{
+		Status:     strconv.Itoa(recorded.StatusCode) + " " + http.StatusText(recorded.StatusCode),
+		StatusCode: recorded.StatusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Request:    req,
+	}
+	if recorded.Chunks != nil {
+		header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Body = &chunkReader{chunks: recorded.Chunks}
+		return res
+	}
+	res.ContentLength = int64(len(recorded.Body))
+	res.Body = io.NopCloser(bytes.NewReader(recorded.Body))
+	return res
+}
+
+// chunkReader returns at most one recorded chunk per Read, like a live
+// stream would.
+type chunkReader struct {
+	chunks  []string
+	current string
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	if cr.current == "" {
+		if len(cr.chunks) == 0 {
+			return 0, io.EOF
+		}
+		cr.current, cr.chunks = cr.chunks[0], cr.chunks[1:]
+	}
+	n := copy(p, cr.current)
+	cr.current = cr.current[n:]
+	return n, nil
+}
+
+func (cr *chunkReader) Close() error {
+	return nil
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	buffer bytes.Buffer
+	done   func([]byte)
+	closed bool
+}
+
+func (rb *recordingBody) Read(p []byte) (int, error) {
+	n, err := rb.ReadCloser.Read(p)
+	rb.buffer.Write(p[:n])
+	return n, err
+}
+
+func (rb *recordingBody) Close() error {
+	if !rb.closed {
+		rb.closed = true
+		// read what the caller left, so that the recording is complete
+		io.Copy(&rb.buffer, rb.ReadCloser)
+		rb.done(rb.buffer.Bytes())
+	}
+	return rb.ReadCloser.Close()
+}
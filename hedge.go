@@ -0,0 +1,130 @@
+package gollum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// errHedgeLost stops the stream of the slower of two hedged requests.
+var errHedgeLost = fmt.Errorf("hedged request lost: %w", context.Canceled)
+
+type hedgeResult struct {
+	slot int
+	sent CompletionRequest
+	res  CompletionResponse
+	err  error
+}
+
+// hedge sends request to the backend first, and to second too when first
+// is slower than HedgeAfter or fails with a fallback error. The first
+// successful answer, or the first stream to deliver a chunk, wins and the
+// other request is canceled. An error is only returned once both requests
+// are done. delivered reports whether chunks were passed to the streaming
+// function.
+func (r *Router) hedge(request *CompletionRequest, first, second int) (sent CompletionRequest, res CompletionResponse, err error, delivered bool) {
+	parent := request.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	// winner is the slot that answered first, or -1
+	var winner atomic.Int32
+	winner.Store(-1)
+	results := make(chan hedgeResult, 2)
+	cancels := []context.CancelFunc{}
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	launch := func(slot, i int) {
+		ctx, cancel := context.WithCancel(parent)
+		cancels = append(cancels, cancel)
+		backend := r.backends[i]
+		attempt := *request
+		attempt.Model = backend.model(request.Model)
+		attempt.Ctx = ctx
+
+		var stream StreamingFunction
+		if r.stream {
+			stream = func(res CompletionResponse) error {
+				if !winner.CompareAndSwap(-1, int32(slot)) && winner.Load() != int32(slot) {
+					return errHedgeLost
+				}
+				return r.streamFunction(res)
+			}
+		}
+
+		go func() {
+			start := time.Now()
+			res, err := backend.Client.completeFunc()(attempt, stream)
+			// canceled requests, the loser included, say nothing of the
+			// latency of the backend
+			switch {
+			case errors.Is(err, context.Canceled) || parent.Err() != nil:
+			case err != nil:
+				r.observe(i, max(time.Since(start), failureLatency(backend.Client)))
+			default:
+				r.observe(i, time.Since(start))
+			}
+			attempt.Ctx = request.Ctx
+			results <- hedgeResult{slot: slot, sent: attempt, res: res, err: err}
+		}()
+	}
+
+	backends := []int{first, second}
+	launch(0, first)
+	launched, pending := 1, 1
+	timer := time.NewTimer(r.HedgeAfter)
+	defer timer.Stop()
+
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if launched == 1 {
+				launch(1, backends[1])
+				launched++
+				pending++
+			}
+		case result := <-results:
+			pending--
+			won := winner.Load() == int32(result.slot)
+			if result.err == nil && (won || winner.CompareAndSwap(-1, int32(result.slot))) {
+				return result.sent, result.res, nil, r.stream
+			}
+			if won {
+				// the stream of the winner failed after its first chunk
+				return result.sent, result.res, result.err, true
+			}
+			if w := winner.Load(); w != -1 && w != int32(result.slot) {
+				// the other request is already streaming
+				continue
+			}
+			// an error that does not fall back is preferred, as it is the
+			// one a single request would have returned
+			if last.err == nil || r.shouldFallback(last.err) {
+				last = result
+			}
+			if parent.Err() != nil {
+				return last.sent, last.res, last.err, false
+			}
+			if !r.shouldFallback(result.err) {
+				// the other request may still succeed
+				continue
+			}
+			// fall back right away rather than waiting for the delay
+			if launched == 1 {
+				launch(1, backends[1])
+				launched++
+				pending++
+			}
+		}
+	}
+
+	return last.sent, last.res, last.err, false
+}
@@ -0,0 +1,145 @@
+package gollum
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBackend is an Ollama server answering after delay, with content on
+// success or with status when it is an error code.
+type fakeBackend struct {
+	delay   time.Duration
+	status  int
+	content string
+	calls   atomic.Int32
+}
+
+func (fb *fakeBackend) client(t *testing.T) LLMClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fb.calls.Add(1)
+		select {
+		case <-time.After(fb.delay):
+		case <-r.Context().Done():
+			return
+		}
+		if fb.status >= http.StatusBadRequest {
+			w.WriteHeader(fb.status)
+			fmt.Fprintf(w, `{"error":"%s"}`, http.StatusText(fb.status))
+			return
+		}
+		fmt.Fprintf(w, `{"model":"m","message":{"role":"assistant","content":%q},"done":true}`, fb.content)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(WithProvider(OLLAMA), WithAPIBase(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func newTestRouter(t *testing.T, strategy RouteStrategy, backends ...*fakeBackend) *Router {
+	t.Helper()
+	routed := make([]Backend, len(backends))
+	for i, fb := range backends {
+		routed[i] = Backend{Client: fb.client(t), Weight: 1}
+	}
+	router, err := NewRouter(strategy, routed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+// No duplicate request is sent when the first backend answers in time.
+func TestHedgeNotSent(t *testing.T) {
+	fast := &fakeBackend{content: "fast"}
+	other := &fakeBackend{content: "other"}
+	router := newTestRouter(t, Fallback, fast, other)
+	router.HedgeAfter = time.Second
+
+	_, res, err := router.Complete(WithModel("m"), WithMessage("hi"))
+	if err != nil || res.Content() != "fast" {
+		t.Fatalf("response %q, error %v", res.Content(), err)
+	}
+	if calls := other.calls.Load(); calls != 0 {
+		t.Errorf("the hedged request was sent %d times", calls)
+	}
+}
+
+// A hedged backend failing fast is charged the client timeout, and does
+// not become the lowest latency one.
+func TestHedgeChargesFailures(t *testing.T) {
+	failing := &fakeBackend{status: http.StatusInternalServerError}
+	healthy := &fakeBackend{delay: 20 * time.Millisecond, content: "ok"}
+	router := newTestRouter(t, LowestLatency, failing, healthy)
+	router.HedgeAfter = 100 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		_, res, err := router.Complete(WithModel("m"), WithMessage("hi"))
+		if err != nil || res.Content() != "ok" {
+			t.Fatalf("request %d: %q, %v", i, res.Content(), err)
+		}
+	}
+	latency := router.Latency()
+	if latency[0] < defaultTimeout {
+		t.Errorf("failing backend latency = %s, want at least %s", latency[0], defaultTimeout)
+	}
+	if latency[1] <= 0 || latency[1] >= latency[0] {
+		t.Errorf("latencies = %v", latency)
+	}
+	if calls := failing.calls.Load(); calls != 1 {
+		t.Errorf("the failing backend was tried first %d times", calls)
+	}
+}
+
+// An error that does not fall back is only returned once the other hedged
+// request has failed too.
+func TestHedgeWaitsForOtherRequest(t *testing.T) {
+	rejecting := &fakeBackend{delay: 30 * time.Millisecond, status: http.StatusBadRequest}
+	slow := &fakeBackend{delay: 80 * time.Millisecond, content: "ok"}
+	router := newTestRouter(t, Fallback, rejecting, slow)
+	router.HedgeAfter = 10 * time.Millisecond
+
+	_, res, err := router.Complete(WithModel("m"), WithMessage("hi"))
+	if err != nil || res.Content() != "ok" {
+		t.Fatalf("response %q, error %v", res.Content(), err)
+	}
+
+	failing := &fakeBackend{delay: 80 * time.Millisecond, status: http.StatusInternalServerError}
+	router = newTestRouter(t, Fallback, rejecting, failing)
+	router.HedgeAfter = 10 * time.Millisecond
+	start := time.Now()
+	_, _, err = router.Complete(WithModel("m"), WithMessage("hi"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("error = %v, want the bad request", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("returned after %s, before the other request was done", elapsed)
+	}
+}
+
+// The canceled loser of a hedge is not counted in the latency average.
+func TestHedgeLoserNotObserved(t *testing.T) {
+	slow := &fakeBackend{delay: 300 * time.Millisecond, content: "slow"}
+	fast := &fakeBackend{delay: 20 * time.Millisecond, content: "fast"}
+	router := newTestRouter(t, Fallback, slow, fast)
+	router.HedgeAfter = 10 * time.Millisecond
+
+	_, res, err := router.Complete(WithModel("m"), WithMessage("hi"))
+	if err != nil || res.Content() != "fast" {
+		t.Fatalf("response %q, error %v", res.Content(), err)
+	}
+	// the loser returns once its cancellation reaches it
+	time.Sleep(100 * time.Millisecond)
+	if latency := router.Latency(); latency[0] != 0 || latency[1] == 0 {
+		t.Errorf("latencies = %v", latency)
+	}
+}
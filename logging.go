@@ -0,0 +1,264 @@
+package gollum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	redacted = "[REDACTED]"
+	// bodies larger than this are truncated in the logs
+	maxLoggedBody = 64 * 1024
+)
+
+var secretHeaders = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key", "X-Amz-Security-Token"}
+
+// RedactContent replaces a message content with its length. It is used by
+// WithContentRedaction when no function is given.
+func RedactContent(content string) string {
+	if content == "" {
+		return content
+	}
+	return fmt.Sprintf("[REDACTED %d chars]", len(content))
+}
+
+type requestLogger struct {
+	logger        *slog.Logger
+	provider      llmProvider
+	apiKey        string
+	redactContent func(string) string
+}
+
+// redactSecrets removes the API key from any logged string, e.g. transport
+// errors that quote the Gemini URL with its key query parameter.
+func (rl requestLogger) redactSecrets(s string) string {
+	if rl.apiKey == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, rl.apiKey, redacted)
+}
+
+func (rl requestLogger) middleware(next CompleteFunc) CompleteFunc {
+	return func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+		ctx := request.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		attrs := []any{
+			slog.String("provider", rl.provider.String()),
+			slog.String("model", request.Model),
+			slog.Bool("stream", stream != nil),
+			slog.Int("messages", len(request.Messages)),
+			slog.Int("tools", len(request.Tools)),
+		}
+		rl.logger.DebugContext(ctx, "completion request", attrs...)
+
+		var last CompletionResponse
+		chunks := 0
+		if stream != nil {
+			streamFunction := stream
+			stream = func(res CompletionResponse) error {
+				chunks++
+				if res.StatusCode != 0 {
+					last.StatusCode = res.StatusCode
+					last.Header = res.Header
+				}
+				if res.Usage.TotalTokens > 0 {
+					last.Usage = res.Usage
+				}
+				return streamFunction(res)
+			}
+		}
+
+		start := time.Now()
+		res, err := next(request, stream)
+		if stream == nil {
+			last = res
+		}
+
+		attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			attrs = append(attrs, slog.Int("status", apiErr.StatusCode), slog.String("request_id", apiErr.RequestID))
+		} else if last.StatusCode != 0 {
+			attrs = append(attrs, slog.Int("status", last.StatusCode))
+		}
+		if stream != nil {
+			attrs = append(attrs, slog.Int("chunks", chunks))
+		}
+		attrs = append(attrs,
+			slog.Int("prompt_tokens", last.Usage.PromptTokens),
+			slog.Int("completion_tokens", last.Usage.CompletionTokens),
+			slog.Int("total_tokens", last.Usage.TotalTokens),
+		)
+
+		if err != nil {
+			attrs = append(attrs, slog.String("error", rl.redactSecrets(err.Error())))
+			rl.logger.ErrorContext(ctx, "completion failed", attrs...)
+			return res, err
+		}
+		rl.logger.DebugContext(ctx, "completion response", attrs...)
+
+		return res, err
+	}
+}
+
+// loggingTransport logs every HTTP exchange with its full bodies at debug
+// level, with secrets and optionally message contents redacted.
+type loggingTransport struct {
+	requestLogger
+	next http.RoundTripper
+}
+
+func (lt loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	lt.logger.DebugContext(ctx, "http request",
+		slog.String("method", req.Method),
+		slog.String("url", lt.redactURL(req.URL)),
+		slog.Any("header", lt.redactHeader(req.Header)),
+		slog.String("body", lt.redactBody(body, req.Header.Get("Content-Type"))),
+	)
+
+	start := time.Now()
+	res, err := lt.next.RoundTrip(req)
+	if err != nil {
+		lt.logger.DebugContext(ctx, "http error", slog.String("url", lt.redactURL(req.URL)), slog.String("error", lt.redactSecrets(err.Error())))
+		return res, err
+	}
+
+	// the body is logged once it has been consumed, so streams are not held
+	res.Body = &loggedBody{
+		ReadCloser: res.Body,
+		done: func(body []byte) {
+			lt.logger.DebugContext(ctx, "http response",
+				slog.String("url", lt.redactURL(req.URL)),
+				slog.Int("status", res.StatusCode),
+				slog.Duration("latency", time.Since(start)),
+				slog.Any("header", lt.redactHeader(res.Header)),
+				slog.String("body", lt.redactBody(body, res.Header.Get("Content-Type"))),
+			)
+		},
+	}
+
+	return res, nil
+}
+
+func (lt loggingTransport) redactURL(u *url.URL) string {
+	redactedURL := *u
+	query := redactedURL.Query()
+	for _, name := range []string{"key", "api_key", "X-Amz-Security-Token", "X-Amz-Signature"} {
+		if query.Has(name) {
+			query.Set(name, redacted)
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+	return lt.redactSecrets(redactedURL.String())
+}
+
+func (lt loggingTransport) redactHeader(header http.Header) http.Header {
+	redactedHeader := header.Clone()
+	for _, name := range secretHeaders {
+		if redactedHeader.Get(name) != "" {
+			redactedHeader.Set(name, redacted)
+		}
+	}
+	return redactedHeader
+}
+
+func (lt loggingTransport) redactBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if !strings.Contains(contentType, "json") && !strings.HasPrefix(contentType, "text/") {
+		return fmt.Sprintf("[%d bytes of %s]", len(body), contentType)
+	}
+	if lt.redactContent != nil {
+		body = redactJSONContent(body, lt.redactContent)
+	}
+	text := lt.redactSecrets(string(body))
+	if len(text) > maxLoggedBody {
+		text = text[:maxLoggedBody] + "...[truncated]"
+	}
+	return text
+}
+
+// redactJSONContent redacts the message text fields of every JSON document
+// in body. Streaming bodies are handled line by line.
+func redactJSONContent(body []byte, redact func(string) string) []byte {
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		prefix := []byte{}
+		trimmed := bytes.TrimSpace(line)
+		if bytes.HasPrefix(trimmed, []byte("data:")) {
+			prefix = []byte("data: ")
+			trimmed = bytes.TrimSpace(trimmed[len("data:"):])
+		}
+		var doc any
+		if json.Unmarshal(trimmed, &doc) != nil {
+			continue
+		}
+		redacted, err := json.Marshal(redactValue(doc, redact))
+		if err != nil {
+			continue
+		}
+		lines[i] = append(prefix, redacted...)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+func redactValue(value any, redact func(string) string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if s, ok := field.(string); ok && (key == "content" || key == "text" || key == "input" || key == "arguments") {
+				v[key] = redact(s)
+				continue
+			}
+			v[key] = redactValue(field, redact)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item, redact)
+		}
+	}
+	return value
+}
+
+type loggedBody struct {
+	io.ReadCloser
+	buffer bytes.Buffer
+	done   func([]byte)
+	closed bool
+}
+
+func (lb *loggedBody) Read(p []byte) (int, error) {
+	n, err := lb.ReadCloser.Read(p)
+	if remaining := maxLoggedBody + 1 - lb.buffer.Len(); remaining > 0 {
+		lb.buffer.Write(p[:min(n, remaining)])
+	}
+	return n, err
+}
+
+func (lb *loggedBody) Close() error {
+	if !lb.closed {
+		lb.closed = true
+		lb.done(lb.buffer.Bytes())
+	}
+	return lb.ReadCloser.Close()
+}
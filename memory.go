@@ -0,0 +1,185 @@
+package gollum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	m "github.com/azr4e1/gollum/message"
+)
+
+const defaultSummaryPrompt = `You maintain the memory of a conversation between a user and an assistant. Update the summary with the new messages. Keep every fact, decision, name and open question that may matter later, drop small talk, and answer with the summary only.`
+
+// SummaryMemory keeps long chats within a token budget by summarizing
+// their oldest turns with an LLM. The running summary is stored in the chat,
+// so it is persisted with it, and WithChat sends it along with the system
+// message.
+type SummaryMemory struct {
+	Client LLMClient
+	Model  string
+	// Prompt is the system message of the summarization requests.
+	Prompt string
+	// MaxTokens is the estimated chat size above which the oldest turns
+	// are summarized.
+	MaxTokens int
+	// KeepTokens is the size of the recent turns kept verbatim. It
+	// defaults to half of MaxTokens.
+	KeepTokens int
+	// Counter estimates the tokens of a message. It defaults to
+	// message.EstimateTokens.
+	Counter m.TokenCounter
+}
+
+func NewSummaryMemory(client LLMClient, model string, maxTokens int) (*SummaryMemory, error) {
+	if model == "" {
+		return nil, errors.New("summary model is empty.")
+	}
+	if maxTokens <= 0 {
+		return nil, errors.New("summary threshold must be greater than 0.")
+	}
+	return &SummaryMemory{
+		Client:    client,
+		Model:     model,
+		Prompt:    defaultSummaryPrompt,
+		MaxTokens: maxTokens,
+	}, nil
+}
+
+func (sm *SummaryMemory) counter() m.TokenCounter {
+	if sm.Counter != nil {
+		return sm.Counter
+	}
+	return m.EstimateTokens
+}
+
+// Compact summarizes the oldest turns of chat into its summary when the
+// chat is larger than MaxTokens, and reports whether it did. The newest turn
+// is always kept, even when it alone is larger than KeepTokens. chat is only
+// changed when the summarization succeeds.
+func (sm *SummaryMemory) Compact(ctx context.Context, chat *m.Chat) (bool, error) {
+	counter := sm.counter()
+	_, full := chat.Window(math.MaxInt, counter)
+	if full.Tokens <= sm.MaxTokens {
+		return false, nil
+	}
+
+	keep := sm.KeepTokens
+	if keep <= 0 {
+		keep = sm.MaxTokens / 2
+	}
+	// the current summary is left out of the window, as the new one
+	// replaces it
+	unsummarized := *chat
+	unsummarized.SetSummary("")
+	_, fixed := unsummarized.Window(0, counter)
+	if fixed.Tokens > keep {
+		return false, errors.New("system and pinned messages exceed the token budget.")
+	}
+	// the newest turn holds the current question, so it is pinned in a copy
+	// of the history to be kept even when it is over the budget
+	history := chat.History()
+	if len(history) > 0 {
+		latest := slices.Clone(history)
+		latest[len(latest)-1].Pinned = true
+		unsummarized.SetHistory(latest)
+	}
+	kept, report := unsummarized.Window(keep, counter)
+	if len(report.Dropped) == 0 {
+		return false, nil
+	}
+	if len(kept) == 0 {
+		return false, errors.New("no message left to send after compacting the chat.")
+	}
+	kept[len(kept)-1].Pinned = history[len(history)-1].Pinned
+
+	summary, err := sm.summarize(ctx, chat.Summary(), report.Dropped)
+	if err != nil {
+		return false, err
+	}
+	chat.SetHistory(kept)
+	chat.SetSummary(summary)
+
+	return true, nil
+}
+
+func (sm *SummaryMemory) summarize(ctx context.Context, summary string, messages []m.Message) (string, error) {
+	transcript := strings.Builder{}
+	if summary != "" {
+		fmt.Fprintf(&transcript, "Current summary:\n%s\n\n", summary)
+	}
+	transcript.WriteString("New messages:\n")
+	for _, mess := range messages {
+		transcript.WriteString(transcriptLine(mess))
+	}
+
+	chat := m.NewChat(m.UserMessage(transcript.String()))
+	prompt := sm.Prompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+	chat.SetSystemMessage(prompt)
+
+	client := sm.Client
+	client.DisableStream()
+	options := []completionOption{WithModel(sm.Model), WithChat(chat)}
+	if ctx != nil {
+		options = append(options, WithContext(ctx))
+	}
+	_, res, err := client.Complete(options...)
+	if err != nil {
+		return "", err
+	}
+	if res.Content() == "" {
+		return "", errors.New("summary is empty.")
+	}
+
+	return strings.TrimSpace(res.Content()), nil
+}
+
+func transcriptLine(mess m.Message) string {
+	line := strings.Builder{}
+	for _, tc := range mess.ToolCalls {
+		fmt.Fprintf(&line, "%s called %s(%s)\n", mess.Role, tc.Name, tc.Arguments)
+	}
+	if mess.Content != "" {
+		fmt.Fprintf(&line, "%s: %s\n", mess.Role, mess.Content)
+	}
+	return line.String()
+}
+
+// WithSummaryMemory compacts chat with memory, then sends it like
+// WithChat. chat is updated in place, so that the summary is kept for the
+// next turns. The context of the summarization is the one set by
+// WithContext, when it comes before this option.
+func WithSummaryMemory(memory *SummaryMemory, chat *m.Chat) completionOption {
+	return func(oR *CompletionRequest) error {
+		if memory == nil || chat == nil {
+			return errors.New("summary memory and chat cannot be nil.")
+		}
+		_, err := memory.Compact(oR.Ctx, chat)
+		if err != nil {
+			return err
+		}
+
+		return WithChat(*chat)(oR)
+	}
+}
+
+// systemWithSummary appends the summary of the chat to its system message.
+func systemWithSummary(chat m.Chat) m.Message {
+	system := chat.SystemMessage()
+	summary := chat.Summary()
+	if summary == "" {
+		return system
+	}
+	content := "Summary of the earlier conversation:\n" + summary
+	if system.Content != "" {
+		content = system.Content + "\n\n" + content
+	}
+	chat.SetSystemMessage(content)
+
+	return chat.SystemMessage()
+}
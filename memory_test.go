@@ -0,0 +1,68 @@
+package gollum
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	m "github.com/azr4e1/gollum/message"
+)
+
+// A newest turn larger than KeepTokens is kept verbatim, and only the
+// older turns are summarized.
+func TestSummaryMemoryKeepsLargeTurn(t *testing.T) {
+	var mu sync.Mutex
+	requests := [][]m.Message{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Messages []m.Message `json:"messages"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		requests = append(requests, body.Messages)
+		mu.Unlock()
+		fmt.Fprint(w, `{"model":"m","message":{"role":"assistant","content":"The user greeted the assistant."},"done":true}`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(WithProvider(OLLAMA), WithAPIBase(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory, err := NewSummaryMemory(client, "m", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	question := strings.Repeat("a", 400)
+	chat := m.NewChat(m.UserMessage("hi"), m.AssistantMessage("hello"), m.UserMessage(question))
+
+	_, _, err = client.Complete(WithModel("m"), WithSummaryMemory(memory, &chat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := chat.History()
+	if len(history) != 1 || history[0].Content != question || history[0].Pinned {
+		t.Errorf("history = %+v", history)
+	}
+	if chat.Summary() != "The user greeted the assistant." {
+		t.Errorf("summary = %q", chat.Summary())
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want the summary and the completion", len(requests))
+	}
+	transcript := requests[0][len(requests[0])-1].Content
+	if !strings.Contains(transcript, "user: hi") || !strings.Contains(transcript, "assistant: hello") || strings.Contains(transcript, question) {
+		t.Errorf("summarized %q", transcript)
+	}
+	sent := requests[1]
+	if len(sent) != 2 || !strings.Contains(sent[0].Content, "The user greeted the assistant.") || sent[1].Content != question {
+		t.Errorf("sent %+v", sent)
+	}
+}
@@ -1,6 +1,7 @@
 package message
 
 import (
+	"encoding/json"
 	"errors"
 )
 
@@ -8,6 +9,7 @@ type Chat struct {
 	systemMessage Message
 	messages      []Message
 	limit         int
+	summary       string
 }
 
 func (c *Chat) SetLimit(limit int) error {
@@ -53,6 +55,15 @@ func (c Chat) History() []Message {
 	return c.messages
 }
 
+// SetHistory replaces the messages of the chat, e.g. with the ones kept by
+// Window. The limit of the chat still applies.
+func (c *Chat) SetHistory(messages []Message) {
+	c.messages = messages
+	if c.limit > 0 && len(c.messages) > c.limit {
+		c.messages = c.messages[len(c.messages)-c.limit:]
+	}
+}
+
 func (c Chat) IsEmpty() bool {
 	if c.messages == nil || len(c.messages) == 0 {
 		return true
@@ -87,6 +98,15 @@ func (c *Chat) SystemMessage() Message {
 	return c.systemMessage
 }
 
+// Summary is the running summary of the messages no longer in the chat.
+func (c Chat) Summary() string {
+	return c.summary
+}
+
+func (c *Chat) SetSummary(summary string) {
+	c.summary = summary
+}
+
 func (c *Chat) UserMessages() []Message {
 	messages := []Message{}
 	if c.messages == nil {
@@ -114,3 +134,35 @@ func (c *Chat) AssistantMessages() []Message {
 
 	return messages
 }
+
+type chatJSON struct {
+	System   *Message  `json:"system,omitempty"`
+	Messages []Message `json:"messages"`
+	Limit    int       `json:"limit,omitempty"`
+	Summary  string    `json:"summary,omitempty"`
+}
+
+func (c Chat) MarshalJSON() ([]byte, error) {
+	cj := chatJSON{Messages: c.History(), Limit: c.limit, Summary: c.summary}
+	if c.systemMessage.Role != "" {
+		cj.System = &c.systemMessage
+	}
+	return json.Marshal(cj)
+}
+
+func (c *Chat) UnmarshalJSON(data []byte) error {
+	cj := chatJSON{}
+	err := json.Unmarshal(data, &cj)
+	if err != nil {
+		return err
+	}
+	if cj.Limit < 0 {
+		return errors.New("limit cannot be < 0.")
+	}
+
+	*c = Chat{messages: cj.Messages, limit: cj.Limit, summary: cj.Summary}
+	if cj.System != nil {
+		c.systemMessage = *cj.System
+	}
+	return nil
+}
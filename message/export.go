@@ -0,0 +1,256 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var roleTitles = map[string]string{
+	system:    "System",
+	user:      "User",
+	assistant: "Assistant",
+	tool:      "Tool",
+}
+
+func roleTitle(role string) string {
+	if title, ok := roleTitles[role]; ok {
+		return title
+	}
+	return role
+}
+
+// allMessages returns the system message, when set, followed by the history.
+func (c Chat) allMessages() []Message {
+	messages := []Message{}
+	if c.systemMessage.Content != "" {
+		messages = append(messages, c.systemMessage)
+	}
+	return append(messages, c.History()...)
+}
+
+// prettyJSON indents arguments for display, or returns them unchanged when
+// they are not valid JSON.
+func prettyJSON(raw []byte) string {
+	indented := bytes.Buffer{}
+	if json.Indent(&indented, raw, "", "  ") != nil {
+		return string(raw)
+	}
+	return indented.String()
+}
+
+// Markdown renders the chat as a Markdown transcript. Tool calls and tool
+// results are collapsible blocks.
+func (c Chat) Markdown() string {
+	md := strings.Builder{}
+	for i, m := range c.allMessages() {
+		if i > 0 {
+			md.WriteString("\n---\n\n")
+		}
+		if m.Role == tool {
+			fmt.Fprintf(&md, "<details>\n<summary>Tool result %s</summary>\n\n```\n%s\n```\n\n</details>\n", m.ToolCallId, m.Content)
+			continue
+		}
+		fmt.Fprintf(&md, "**%s**\n\n", roleTitle(m.Role))
+		if m.Content != "" {
+			fmt.Fprintf(&md, "%s\n", m.Content)
+		}
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&md, "\n<details>\n<summary>Tool call: %s</summary>\n\n```json\n%s\n```\n\n</details>\n", tc.Name, prettyJSON(tc.Arguments))
+		}
+	}
+	return md.String()
+}
+
+var htmlTemplate = template.Must(template.New("chat").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; color: #1f2328; }
+.message { border-radius: 0.5rem; padding: 0.75rem 1rem; margin: 1rem 0; white-space: pre-wrap; }
+.role { font-weight: 600; font-size: 0.85rem; text-transform: uppercase; margin-bottom: 0.25rem; white-space: normal; }
+.system { background: #f6f8fa; color: #57606a; }
+.user { background: #ddf4ff; }
+.assistant { background: #ffffff; border: 1px solid #d0d7de; }
+.tool { background: #fff8c5; }
+details { margin-top: 0.5rem; white-space: normal; }
+pre { background: #f6f8fa; padding: 0.5rem; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Messages}}<div class="message {{.Role}}">
+<div class="role">{{.Title}}</div>
+{{- if .Tool}}
+<details><summary>Tool result {{.ToolCallId}}</summary><pre>{{.Content}}</pre></details>
+{{- else}}{{.Content}}{{end}}
+{{- range .ToolCalls}}
+<details><summary>Tool call: {{.Name}}</summary><pre>{{.Arguments}}</pre></details>
+{{- end}}
+</div>
+{{end}}</body>
+</html>
+`))
+
+type htmlToolCall struct {
+	Name      string
+	Arguments string
+}
+
+type htmlMessage struct {
+	Role       string
+	Title      string
+	Content    string
+	Tool       bool
+	ToolCallId string
+	ToolCalls  []htmlToolCall
+}
+
+// HTML renders the chat as a standalone HTML page.
+func (c Chat) HTML(title string) (string, error) {
+	messages := []htmlMessage{}
+	for _, m := range c.allMessages() {
+		hm := htmlMessage{
+			Role:       m.Role,
+			Title:      roleTitle(m.Role),
+			Content:    m.Content,
+			Tool:       m.Role == tool,
+			ToolCallId: m.ToolCallId,
+		}
+		for _, tc := range m.ToolCalls {
+			hm.ToolCalls = append(hm.ToolCalls, htmlToolCall{Name: tc.Name, Arguments: prettyJSON(tc.Arguments)})
+		}
+		messages = append(messages, hm)
+	}
+
+	page := strings.Builder{}
+	err := htmlTemplate.Execute(&page, struct {
+		Title    string
+		Messages []htmlMessage
+	}{title, messages})
+	if err != nil {
+		return "", err
+	}
+	return page.String(), nil
+}
+
+// fineTuningExample is a line of the OpenAI chat fine-tuning format.
+type fineTuningExample struct {
+	Messages []fineTuningMessage `json:"messages"`
+	Tools    []json.RawMessage   `json:"tools,omitempty"`
+}
+
+type fineTuningMessage struct {
+	Role       string               `json:"role"`
+	Content    *string              `json:"content,omitempty"`
+	ToolCalls  []fineTuningToolCall `json:"tool_calls,omitempty"`
+	ToolCallId string               `json:"tool_call_id,omitempty"`
+}
+
+type fineTuningToolCall struct {
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// FineTuningJSON returns the chat as a line of the OpenAI chat fine-tuning
+// format, without the trailing newline. tools are the definitions of the
+// tools available in the conversation, e.g. gollum.Tool values.
+func (c Chat) FineTuningJSON(tools ...any) ([]byte, error) {
+	example := fineTuningExample{Messages: []fineTuningMessage{}}
+	for _, m := range c.allMessages() {
+		ftm := fineTuningMessage{Role: m.Role, ToolCallId: m.ToolCallId}
+		if m.Content != "" || len(m.ToolCalls) == 0 {
+			content := m.Content
+			ftm.Content = &content
+		}
+		for _, tc := range m.ToolCalls {
+			call := fineTuningToolCall{Id: tc.Id, Type: "function"}
+			call.Function.Name = tc.Name
+			call.Function.Arguments = string(tc.Arguments)
+			ftm.ToolCalls = append(ftm.ToolCalls, call)
+		}
+		example.Messages = append(example.Messages, ftm)
+	}
+	for _, t := range tools {
+		raw, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		example.Tools = append(example.Tools, raw)
+	}
+
+	return json.Marshal(example)
+}
+
+// WriteFineTuningJSONL writes chats in the OpenAI chat fine-tuning format,
+// one per line, all with the same tools.
+func WriteFineTuningJSONL(w io.Writer, chats []Chat, tools ...any) error {
+	for _, chat := range chats {
+		line, err := chat.FineTuningJSON(tools...)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(line, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFineTuningJSONL reads chats in the OpenAI chat fine-tuning format.
+// A leading system message becomes the system message of the chat. The
+// tool definitions are not part of a Chat and are ignored.
+func ReadFineTuningJSONL(r io.Reader) ([]Chat, error) {
+	chats := []Chat{}
+	scanner := bufio.NewScanner(r)
+	// examples with long conversations easily exceed the default 64KB
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		example := fineTuningExample{}
+		err := json.Unmarshal(text, &example)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		chat := Chat{}
+		for i, ftm := range example.Messages {
+			m := Message{Role: ftm.Role, ToolCallId: ftm.ToolCallId}
+			if ftm.Content != nil {
+				m.Content = *ftm.Content
+			}
+			for _, call := range ftm.ToolCalls {
+				arguments := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(arguments) {
+					arguments, _ = json.Marshal(call.Function.Arguments)
+				}
+				m.ToolCalls = append(m.ToolCalls, ToolCall{Id: call.Id, Type: call.Type, Name: call.Function.Name, Arguments: arguments})
+			}
+			if i == 0 && m.Role == system {
+				chat.systemMessage = m
+				continue
+			}
+			chat.Add(m)
+		}
+		chats = append(chats, chat)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return chats, nil
+}
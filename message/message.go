@@ -6,12 +6,17 @@ const (
 	system    = "system"
 	assistant = "assistant"
 	user      = "user"
+	tool      = "tool"
 )
 
 type Message struct {
 	Role      string     `json:"role"`
 	Content   string     `json:"content"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallId is the id of the call a tool message answers.
+	ToolCallId string `json:"tool_call_id,omitempty"`
+	// Pinned messages are never dropped by Chat.Window.
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 type ToolCall struct {
@@ -19,6 +24,9 @@ type ToolCall struct {
 	Type      string          `json:"type"`
 	Name      string          `json:"name"`
 	Arguments json.RawMessage `json:"arguments"`
+	// Index identifies the call in stream chunks that carry a fragment of
+	// it. It is nil in whole messages.
+	Index *int `json:"-"`
 }
 
 func UserMessage(content string) Message {
@@ -28,3 +36,8 @@ func UserMessage(content string) Message {
 func AssistantMessage(content string) Message {
 	return Message{Role: assistant, Content: content}
 }
+
+// ToolMessage is the result of the tool call with id callId.
+func ToolMessage(callId, content string) Message {
+	return Message{Role: tool, Content: content, ToolCallId: callId}
+}
@@ -0,0 +1,165 @@
+// Package sqlitetest tests message.SQLStore against SQLite. It is a
+// separate module, so that gollum does not depend on a SQL driver.
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	m "github.com/azr4e1/gollum/message"
+	_ "modernc.org/sqlite"
+)
+
+func newStore(t *testing.T, table string) *m.SQLStore {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "chats.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store, err := m.NewSQLStore(context.Background(), db, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func sameChat(t *testing.T, got, want m.Chat) {
+	t.Helper()
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("chat = %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestSQLStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, "")
+
+	call := m.AssistantMessage("")
+	call.ToolCalls = []m.ToolCall{{Id: "call_1", Type: "function", Name: "weather", Arguments: []byte(`{"city":"Rome"}`)}}
+	chat := m.NewChat(m.UserMessage("What's the weather in Rome?"), call, m.ToolMessage("call_1", "sunny"))
+	chat.SetSystemMessage("You are a helpful assistant.")
+	chat.SetSummary("The user asked about the weather.")
+	if err := chat.SetLimit(10); err != nil {
+		t.Fatal(err)
+	}
+
+	info := m.ChatInfo{ID: "chat-1", Title: "Weather", Metadata: map[string]string{"user": "42"}}
+	saved, err := store.Save(ctx, info, chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Created.IsZero() || !saved.Created.Equal(saved.Updated) {
+		t.Errorf("created %s, updated %s", saved.Created, saved.Updated)
+	}
+
+	loaded, loadedInfo, err := store.Load(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameChat(t, loaded, chat)
+	if loadedInfo.Title != "Weather" || loadedInfo.Metadata["user"] != "42" ||
+		!loadedInfo.Created.Equal(saved.Created) || !loadedInfo.Updated.Equal(saved.Updated) {
+		t.Errorf("info = %+v, want %+v", loadedInfo, saved)
+	}
+
+	// saving again replaces the chat and keeps the creation time
+	time.Sleep(time.Millisecond)
+	chat.Add(m.AssistantMessage("It's sunny in Rome."))
+	resaved, err := store.Save(ctx, m.ChatInfo{ID: "chat-1", Title: "Rome"}, chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resaved.Created.Equal(saved.Created) || !resaved.Updated.After(saved.Updated) {
+		t.Errorf("created %s, updated %s after saving again", resaved.Created, resaved.Updated)
+	}
+	loaded, loadedInfo, err = store.Load(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameChat(t, loaded, chat)
+	if loadedInfo.Title != "Rome" || loadedInfo.Metadata != nil {
+		t.Errorf("info = %+v", loadedInfo)
+	}
+}
+
+func TestSQLStoreListDelete(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, "conversations")
+
+	infos, err := store.List(ctx)
+	if err != nil || len(infos) != 0 {
+		t.Fatalf("empty store: %v, %v", infos, err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := store.Save(ctx, m.ChatInfo{ID: id}, m.NewChat(m.UserMessage(id))); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// updating a chat moves it first
+	if _, err := store.Save(ctx, m.ChatInfo{ID: "a"}, m.NewChat(m.UserMessage("again"))); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err = store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, info := range infos {
+		ids = append(ids, info.ID)
+	}
+	if len(ids) != 3 || ids[0] != "a" || ids[1] != "c" || ids[2] != "b" {
+		t.Errorf("listed %v, want [a c b]", ids)
+	}
+
+	if err := store.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Load(ctx, "b"); !errors.Is(err, m.ErrChatNotFound) {
+		t.Errorf("load after delete: %v", err)
+	}
+	if err := store.Delete(ctx, "b"); !errors.Is(err, m.ErrChatNotFound) {
+		t.Errorf("delete twice: %v", err)
+	}
+	if infos, _ := store.List(ctx); len(infos) != 2 {
+		t.Errorf("listed %d chats after delete", len(infos))
+	}
+}
+
+func TestSQLStoreInvalid(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "chats.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := m.NewSQLStore(ctx, db, "chats; DROP TABLE chats"); err == nil {
+		t.Error("expected an error for an invalid table name")
+	}
+
+	store, err := m.NewSQLStore(ctx, db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Save(ctx, m.ChatInfo{ID: "../chat"}, m.NewChat()); err == nil {
+		t.Error("expected an error for an invalid id")
+	}
+	if _, _, err := store.Load(ctx, "missing"); !errors.Is(err, m.ErrChatNotFound) {
+		t.Errorf("load missing chat: %v", err)
+	}
+}
@@ -0,0 +1,342 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrChatNotFound = errors.New("chat not found")
+
+// ChatInfo describes a stored chat.
+type ChatInfo struct {
+	ID       string            `json:"id"`
+	Title    string            `json:"title,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Created  time.Time         `json:"created"`
+	Updated  time.Time         `json:"updated"`
+}
+
+// ChatStore persists chats by ID.
+type ChatStore interface {
+	// Save creates or replaces the chat with info.ID. Created and Updated
+	// are set by the store, and the stored info is returned.
+	Save(ctx context.Context, info ChatInfo, chat Chat) (ChatInfo, error)
+	// Load returns ErrChatNotFound for unknown IDs.
+	Load(ctx context.Context, id string) (Chat, ChatInfo, error)
+	// List returns the stored chats, most recently updated first.
+	List(ctx context.Context) ([]ChatInfo, error)
+	Delete(ctx context.Context, id string) error
+}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+func checkID(id string) error {
+	if !validID.MatchString(id) {
+		return errors.New("chat id must only contain letters, digits, '.', '_' and '-'.")
+	}
+	return nil
+}
+
+func sortInfos(infos []ChatInfo) {
+	slices.SortFunc(infos, func(a, b ChatInfo) int {
+		return b.Updated.Compare(a.Updated)
+	})
+}
+
+// FileStore stores every chat as a JSON file in a directory.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+type storedChat struct {
+	Info ChatInfo `json:"info"`
+	Chat Chat     `json:"chat"`
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("store directory is empty.")
+	}
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (fs *FileStore) path(id string) string {
+	return filepath.Join(fs.dir, id+".json")
+}
+
+func (fs *FileStore) read(id string) (storedChat, error) {
+	body, err := os.ReadFile(fs.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return storedChat{}, ErrChatNotFound
+	}
+	if err != nil {
+		return storedChat{}, err
+	}
+	stored := storedChat{}
+	err = json.Unmarshal(body, &stored)
+	return stored, err
+}
+
+func (fs *FileStore) Save(ctx context.Context, info ChatInfo, chat Chat) (ChatInfo, error) {
+	err := checkID(info.ID)
+	if err != nil {
+		return ChatInfo{}, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	now := time.Now().UTC()
+	info.Created = now
+	if existing, err := fs.read(info.ID); err == nil {
+		info.Created = existing.Info.Created
+	}
+	info.Updated = now
+
+	body, err := json.MarshalIndent(storedChat{Info: info, Chat: chat}, "", "  ")
+	if err != nil {
+		return ChatInfo{}, err
+	}
+	// write to a temporary file first, so that a crash never leaves a
+	// truncated chat behind
+	tmp, err := os.CreateTemp(fs.dir, info.ID+".*.tmp")
+	if err != nil {
+		return ChatInfo{}, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ChatInfo{}, err
+	}
+	err = os.Rename(tmp.Name(), fs.path(info.ID))
+	if err != nil {
+		return ChatInfo{}, err
+	}
+
+	return info, nil
+}
+
+func (fs *FileStore) Load(ctx context.Context, id string) (Chat, ChatInfo, error) {
+	err := checkID(id)
+	if err != nil {
+		return Chat{}, ChatInfo{}, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	stored, err := fs.read(id)
+	if err != nil {
+		return Chat{}, ChatInfo{}, err
+	}
+	return stored.Chat, stored.Info, nil
+}
+
+func (fs *FileStore) List(ctx context.Context) ([]ChatInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := []ChatInfo{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || checkID(id) != nil {
+			continue
+		}
+		stored, err := fs.read(id)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, stored.Info)
+	}
+	sortInfos(infos)
+
+	return infos, nil
+}
+
+func (fs *FileStore) Delete(ctx context.Context, id string) error {
+	err := checkID(id)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	err = os.Remove(fs.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrChatNotFound
+	}
+	return err
+}
+
+// SQLStore stores chats in a table of a SQL database. It is written for
+// SQLite 3.35 or later, and works with any database/sql driver for it, e.g.
+// modernc.org/sqlite or github.com/mattn/go-sqlite3.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore creates the table when it does not exist yet. table defaults
+// to "chats".
+func NewSQLStore(ctx context.Context, db *sql.DB, table string) (*SQLStore, error) {
+	if db == nil {
+		return nil, errors.New("database cannot be nil.")
+	}
+	if table == "" {
+		table = "chats"
+	}
+	if !validTable.MatchString(table) {
+		return nil, errors.New("table name must only contain letters, digits and '_'.")
+	}
+
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	metadata TEXT NOT NULL,
+	chat TEXT NOT NULL,
+	created INTEGER NOT NULL,
+	updated INTEGER NOT NULL
+)`)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS `+table+`_updated ON `+table+` (updated)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLStore{db: db, table: table}, nil
+}
+
+var validTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// times are stored as unix nanoseconds, so that they sort as integers
+func fromNanos(nanos int64) time.Time {
+	return time.Unix(0, nanos).UTC()
+}
+
+func (ss *SQLStore) Save(ctx context.Context, info ChatInfo, chat Chat) (ChatInfo, error) {
+	err := checkID(info.ID)
+	if err != nil {
+		return ChatInfo{}, err
+	}
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return ChatInfo{}, err
+	}
+	metadata, err := json.Marshal(info.Metadata)
+	if err != nil {
+		return ChatInfo{}, err
+	}
+
+	now := time.Now().UTC()
+	var created int64
+	err = ss.db.QueryRowContext(ctx, `INSERT INTO `+ss.table+` (id, title, metadata, chat, created, updated)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET title = excluded.title, metadata = excluded.metadata, chat = excluded.chat, updated = excluded.updated
+RETURNING created`,
+		info.ID, info.Title, string(metadata), string(body), now.UnixNano(), now.UnixNano()).Scan(&created)
+	if err != nil {
+		return ChatInfo{}, err
+	}
+	info.Created = fromNanos(created)
+	info.Updated = fromNanos(now.UnixNano())
+
+	return info, nil
+}
+
+func (ss *SQLStore) Load(ctx context.Context, id string) (Chat, ChatInfo, error) {
+	info := ChatInfo{ID: id}
+	var metadata, body string
+	var created, updated int64
+	err := ss.db.QueryRowContext(ctx, `SELECT title, metadata, chat, created, updated FROM `+ss.table+` WHERE id = ?`, id).
+		Scan(&info.Title, &metadata, &body, &created, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chat{}, ChatInfo{}, ErrChatNotFound
+	}
+	if err != nil {
+		return Chat{}, ChatInfo{}, err
+	}
+
+	err = json.Unmarshal([]byte(metadata), &info.Metadata)
+	if err != nil {
+		return Chat{}, ChatInfo{}, err
+	}
+	chat := Chat{}
+	err = json.Unmarshal([]byte(body), &chat)
+	if err != nil {
+		return Chat{}, ChatInfo{}, err
+	}
+	info.Created = fromNanos(created)
+	info.Updated = fromNanos(updated)
+
+	return chat, info, nil
+}
+
+func (ss *SQLStore) List(ctx context.Context) ([]ChatInfo, error) {
+	rows, err := ss.db.QueryContext(ctx, `SELECT id, title, metadata, created, updated FROM `+ss.table+` ORDER BY updated DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	infos := []ChatInfo{}
+	for rows.Next() {
+		info := ChatInfo{}
+		var metadata string
+		var created, updated int64
+		err = rows.Scan(&info.ID, &info.Title, &metadata, &created, &updated)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(metadata), &info.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		info.Created = fromNanos(created)
+		info.Updated = fromNanos(updated)
+		infos = append(infos, info)
+	}
+
+	return infos, rows.Err()
+}
+
+func (ss *SQLStore) Delete(ctx context.Context, id string) error {
+	result, err := ss.db.ExecContext(ctx, `DELETE FROM `+ss.table+` WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+var (
+	_ ChatStore = (*FileStore)(nil)
+	_ ChatStore = (*SQLStore)(nil)
+)
@@ -0,0 +1,207 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// NoParent is the parent of the first messages of a tree.
+const NoParent = -1
+
+// ChatNode is a message of a ChatTree.
+type ChatNode struct {
+	ID       int     `json:"id"`
+	Parent   int     `json:"parent"`
+	Message  Message `json:"message"`
+	Children []int   `json:"children,omitempty"`
+}
+
+// ChatTree is a conversation with branches, as created by editing a
+// previous message or regenerating a reply. One path from a first message
+// to a leaf is active, it is the conversation returned by Chat.
+type ChatTree struct {
+	systemMessage Message
+	nodes         []ChatNode
+	roots         []int
+	active        int
+}
+
+func NewChatTree() *ChatTree {
+	return &ChatTree{active: NoParent}
+}
+
+func (ct *ChatTree) SetSystemMessage(message string) {
+	ct.systemMessage = Message{Role: system, Content: message}
+}
+
+func (ct *ChatTree) node(id int) (*ChatNode, error) {
+	if id < 0 || id >= len(ct.nodes) {
+		return nil, errors.New("message id not in the tree.")
+	}
+	return &ct.nodes[id], nil
+}
+
+func (ct *ChatTree) Node(id int) (ChatNode, error) {
+	node, err := ct.node(id)
+	if err != nil {
+		return ChatNode{}, err
+	}
+	return *node, nil
+}
+
+// Active returns the id of the last message of the active branch, or
+// NoParent when the tree is empty.
+func (ct *ChatTree) Active() int {
+	return ct.active
+}
+
+func (ct *ChatTree) addChild(parent int, m Message) int {
+	id := len(ct.nodes)
+	ct.nodes = append(ct.nodes, ChatNode{ID: id, Parent: parent, Message: m})
+	if parent == NoParent {
+		ct.roots = append(ct.roots, id)
+	} else {
+		ct.nodes[parent].Children = append(ct.nodes[parent].Children, id)
+	}
+	ct.active = id
+	return id
+}
+
+// Add appends messages to the active branch and returns the id of the
+// last one.
+func (ct *ChatTree) Add(messages ...Message) int {
+	for _, m := range messages {
+		ct.addChild(ct.active, m)
+	}
+	return ct.active
+}
+
+// Edit forks the conversation at message id: the new message becomes a
+// sibling of id and the end of the active branch. The original branch is
+// kept and can be switched back to.
+func (ct *ChatTree) Edit(id int, m Message) (int, error) {
+	node, err := ct.node(id)
+	if err != nil {
+		return NoParent, err
+	}
+	return ct.addChild(node.Parent, m), nil
+}
+
+// Regenerate prepares a new reply to the last assistant message of the
+// active branch. The active branch moves back to the message it answered,
+// and the chat to send is returned. Adding the new reply makes it a
+// sibling of the previous one.
+func (ct *ChatTree) Regenerate() (Chat, error) {
+	for id := ct.active; id != NoParent; id = ct.nodes[id].Parent {
+		if ct.nodes[id].Message.Role == assistant {
+			ct.active = ct.nodes[id].Parent
+			return ct.Chat(), nil
+		}
+	}
+	return Chat{}, errors.New("no assistant message to regenerate.")
+}
+
+// Switch makes the branch through message id active, down to its most
+// recent leaf.
+func (ct *ChatTree) Switch(id int) error {
+	node, err := ct.node(id)
+	if err != nil {
+		return err
+	}
+	for len(node.Children) > 0 {
+		node = &ct.nodes[node.Children[len(node.Children)-1]]
+	}
+	ct.active = node.ID
+	return nil
+}
+
+// Siblings returns the ids of the alternative versions of message id, id
+// included, in the order they were created.
+func (ct *ChatTree) Siblings(id int) ([]int, error) {
+	node, err := ct.node(id)
+	if err != nil {
+		return nil, err
+	}
+	if node.Parent == NoParent {
+		return append([]int(nil), ct.roots...), nil
+	}
+	return append([]int(nil), ct.nodes[node.Parent].Children...), nil
+}
+
+// Path returns the ids of the active branch, first message first.
+func (ct *ChatTree) Path() []int {
+	path := []int{}
+	for id := ct.active; id != NoParent; id = ct.nodes[id].Parent {
+		path = append(path, id)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Chat flattens the active branch into a Chat, to be sent with WithChat.
+func (ct *ChatTree) Chat() Chat {
+	path := ct.Path()
+	messages := make([]Message, len(path))
+	for i, id := range path {
+		messages[i] = ct.nodes[id].Message
+	}
+	chat := NewChat(messages...)
+	chat.systemMessage = ct.systemMessage
+	return chat
+}
+
+func (ct *ChatTree) Len() int {
+	return len(ct.nodes)
+}
+
+type chatTreeJSON struct {
+	System *Message   `json:"system,omitempty"`
+	Nodes  []ChatNode `json:"nodes"`
+	Active int        `json:"active"`
+}
+
+func (ct ChatTree) MarshalJSON() ([]byte, error) {
+	ctj := chatTreeJSON{Nodes: ct.nodes, Active: ct.active}
+	if ctj.Nodes == nil {
+		ctj.Nodes = []ChatNode{}
+	}
+	if ct.systemMessage.Role != "" {
+		ctj.System = &ct.systemMessage
+	}
+	return json.Marshal(ctj)
+}
+
+func (ct *ChatTree) UnmarshalJSON(data []byte) error {
+	ctj := chatTreeJSON{}
+	err := json.Unmarshal(data, &ctj)
+	if err != nil {
+		return err
+	}
+
+	// the children are rebuilt from the parents, which are validated,
+	// rather than trusted from the input
+	tree := ChatTree{nodes: ctj.Nodes, active: ctj.Active}
+	for i := range tree.nodes {
+		tree.nodes[i].Children = nil
+	}
+	for i, node := range tree.nodes {
+		if node.ID != i || node.Parent < NoParent || node.Parent >= i {
+			return errors.New("invalid chat tree.")
+		}
+		if node.Parent == NoParent {
+			tree.roots = append(tree.roots, i)
+		} else {
+			tree.nodes[node.Parent].Children = append(tree.nodes[node.Parent].Children, i)
+		}
+	}
+	if tree.active < NoParent || tree.active >= len(tree.nodes) {
+		return errors.New("invalid chat tree.")
+	}
+	if ctj.System != nil {
+		tree.systemMessage = *ctj.System
+	}
+	*ct = tree
+	return nil
+}
@@ -0,0 +1,143 @@
+package message
+
+import (
+	"errors"
+)
+
+// TokenCounter estimates the number of tokens of a message for a model.
+type TokenCounter func(Message) int
+
+// EstimateTokens approximates the tokens of a message from its length,
+// about four characters per token, plus the per message overhead of the
+// chat formats.
+func EstimateTokens(m Message) int {
+	chars := len(m.Content)
+	for _, tc := range m.ToolCalls {
+		chars += len(tc.Name) + len(tc.Arguments)
+	}
+	return chars/4 + 4
+}
+
+// WindowReport describes the result of windowing a chat.
+type WindowReport struct {
+	// Dropped are the messages left out, oldest first.
+	Dropped []Message
+	// Tokens is the estimated size of the kept messages, system message
+	// and summary included.
+	Tokens int
+}
+
+// groups splits messages into the units that are kept or dropped together:
+// an assistant message calling tools with the tool results that follow it,
+// or a single message.
+func groups(messages []Message) [][]Message {
+	groups := [][]Message{}
+	for _, m := range messages {
+		last := len(groups) - 1
+		if m.Role == tool && last >= 0 {
+			head := groups[last][0]
+			if head.Role == assistant && len(head.ToolCalls) > 0 {
+				groups[last] = append(groups[last], m)
+				continue
+			}
+		}
+		groups = append(groups, []Message{m})
+	}
+	return groups
+}
+
+func groupTokens(group []Message, counter TokenCounter) int {
+	tokens := 0
+	for _, m := range group {
+		tokens += counter(m)
+	}
+	return tokens
+}
+
+func isPinned(group []Message) bool {
+	for _, m := range group {
+		if m.Pinned {
+			return true
+		}
+	}
+	return false
+}
+
+// Window returns the most recent messages whose estimated size, with the
+// system message and summary, fits in maxTokens. Pinned messages are always
+// kept, and an assistant tool call is never separated from its tool
+// results. The kept messages form a contiguous recent history, plus the
+// pinned ones before it. counter defaults to EstimateTokens. The chat is not modified.
+func (c Chat) Window(maxTokens int, counter TokenCounter) ([]Message, WindowReport) {
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	report := WindowReport{Dropped: []Message{}}
+	if c.systemMessage.Content != "" {
+		report.Tokens += counter(c.systemMessage)
+	}
+	if c.summary != "" {
+		report.Tokens += counter(Message{Role: system, Content: c.summary})
+	}
+
+	all := groups(c.History())
+	keep := make([]bool, len(all))
+	for i, group := range all {
+		if isPinned(group) {
+			keep[i] = true
+			report.Tokens += groupTokens(group, counter)
+		}
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if keep[i] {
+			continue
+		}
+		tokens := groupTokens(all[i], counter)
+		if report.Tokens+tokens > maxTokens {
+			break
+		}
+		keep[i] = true
+		report.Tokens += tokens
+	}
+
+	kept := []Message{}
+	for i, group := range all {
+		if keep[i] {
+			kept = append(kept, group...)
+		} else {
+			report.Dropped = append(report.Dropped, group...)
+		}
+	}
+
+	return kept, report
+}
+
+// TrimToTokens drops the messages left out by Window from the chat. It
+// fails, leaving the chat unchanged, when the system message, summary and
+// pinned messages alone do not fit in maxTokens.
+func (c *Chat) TrimToTokens(maxTokens int, counter TokenCounter) (WindowReport, error) {
+	kept, report := c.Window(maxTokens, counter)
+	if report.Tokens > maxTokens {
+		return report, errors.New("system and pinned messages exceed the token budget.")
+	}
+	c.messages = kept
+
+	return report, nil
+}
+
+// Pin marks the message at index i so that it is never dropped by Window.
+func (c *Chat) Pin(i int) error {
+	if i < 0 || i >= c.Len() {
+		return errors.New("message index out of range.")
+	}
+	c.messages[i].Pinned = true
+	return nil
+}
+
+func (c *Chat) Unpin(i int) error {
+	if i < 0 || i >= c.Len() {
+		return errors.New("message index out of range.")
+	}
+	c.messages[i].Pinned = false
+	return nil
+}
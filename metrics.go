@@ -0,0 +1,75 @@
+package gollum
+
+import (
+	"errors"
+	"time"
+)
+
+// CompletionMetrics describes a finished Complete call.
+type CompletionMetrics struct {
+	Provider   string
+	Model      string
+	Stream     bool
+	StatusCode int
+	Usage      CompletionUsage
+	Latency    time.Duration
+	// TimeToFirstToken is only set for streams, when the first chunk
+	// arrived.
+	TimeToFirstToken time.Duration
+	Err              error
+	// ErrorType is the ErrorType of Err, empty on success.
+	ErrorType string
+}
+
+// MetricsCollector receives the metrics of every completion. It must be
+// safe for concurrent use.
+type MetricsCollector interface {
+	ObserveCompletion(CompletionMetrics)
+}
+
+func collectMetrics(provider llmProvider, collector MetricsCollector) Middleware {
+	return func(next CompleteFunc) CompleteFunc {
+		return func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+			metrics := CompletionMetrics{
+				Provider: provider.String(),
+				Model:    request.Model,
+				Stream:   stream != nil,
+			}
+
+			start := time.Now()
+			if stream != nil {
+				streamFunction := stream
+				stream = func(res CompletionResponse) error {
+					if metrics.TimeToFirstToken == 0 {
+						metrics.TimeToFirstToken = time.Since(start)
+					}
+					if res.StatusCode != 0 {
+						metrics.StatusCode = res.StatusCode
+					}
+					if res.Usage.TotalTokens > 0 {
+						metrics.Usage = res.Usage
+					}
+					return streamFunction(res)
+				}
+			}
+
+			res, err := next(request, stream)
+			metrics.Latency = time.Since(start)
+			if stream == nil {
+				metrics.StatusCode = res.StatusCode
+				metrics.Usage = res.Usage
+			}
+			if err != nil {
+				metrics.Err = err
+				metrics.ErrorType = ErrorType(err)
+				var apiErr *APIError
+				if errors.As(err, &apiErr) {
+					metrics.StatusCode = apiErr.StatusCode
+				}
+			}
+			collector.ObserveCompletion(metrics)
+
+			return res, err
+		}
+	}
+}
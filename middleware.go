@@ -0,0 +1,107 @@
+package gollum
+
+import "errors"
+
+// CompleteFunc sends a completion request. stream is nil unless streaming
+// is enabled, in which case every chunk is passed to it.
+type CompleteFunc func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error)
+
+// Middleware wraps a CompleteFunc to observe or modify requests, responses
+// and stream chunks, or to answer without calling next at all.
+type Middleware func(next CompleteFunc) CompleteFunc
+
+// completeFunc chains the middleware around the provider call. The first
+// middleware registered is the outermost one.
+func (c LLMClient) completeFunc() CompleteFunc {
+	handler := c.send
+	// the breaker is inside the limiter, so that waiting for capacity
+	// does not count as a failure of the provider
+	if c.breaker != nil {
+		handler = c.breaker.middleware(handler)
+	}
+	if c.limiter != nil {
+		handler = rateLimit(c.limiter)(handler)
+	}
+	handler = trackCost(c.costs, c.budget)(handler)
+	// metrics are outside the breaker, limiter and budget, so that the
+	// requests they reject are counted too
+	if c.metrics != nil {
+		handler = collectMetrics(c.provider, c.metrics)(handler)
+	}
+	if c.semanticCache != nil {
+		handler = c.semanticCache.middleware(c.provider)(handler)
+	}
+	if c.cache != nil {
+		handler = cacheResponses(c.provider, c.cache, c.cacheTTL)(handler)
+	}
+	if c.logger != nil {
+		handler = c.requestLogger().middleware(handler)
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		handler = c.middleware[i](handler)
+	}
+
+	return handler
+}
+
+func (c LLMClient) send(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+	provider := c.provider.String()
+	c.stream = stream != nil
+	c.streamFunction = nil
+	if stream != nil {
+		c.streamFunction = func(res CompletionResponse) error {
+			res.Provider = provider
+			return stream(res)
+		}
+	}
+
+	_, res, err := c.complete(&request)
+	res.Provider = provider
+	res.body = errorBody(err)
+	res.Error = responseError(res.Error, err)
+	return res, err
+}
+
+// errorBody returns the raw reply of a provider error, kept on responses so
+// that their Err matches the error returned with them.
+func errorBody(err error) []byte {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Body
+	}
+	return nil
+}
+
+// responseError fills the error of a response from err when the provider
+// reply had none, e.g. for a plain text error body.
+func responseError(resErr CompletionError, err error) CompletionError {
+	var apiErr *APIError
+	if resErr.Type != "" || resErr.Message != "" || !errors.As(err, &apiErr) {
+		return resErr
+	}
+	return CompletionError{Message: apiErr.Message, Type: apiErr.Type, Code: apiErr.Code}
+}
+
+// SpeechFunc sends a text to speech request.
+type SpeechFunc func(request TTSRequest) (TTSResponse, error)
+
+// SpeechMiddleware wraps a SpeechFunc, like Middleware does for
+// completions.
+type SpeechMiddleware func(next SpeechFunc) SpeechFunc
+
+func (c LLMClient) speechFunc() SpeechFunc {
+	handler := c.sendSpeech
+	for i := len(c.speechMiddleware) - 1; i >= 0; i-- {
+		handler = c.speechMiddleware[i](handler)
+	}
+
+	return handler
+}
+
+func (c LLMClient) sendSpeech(request TTSRequest) (TTSResponse, error) {
+	_, res, err := c.textToSpeech(&request)
+	res.Provider = c.provider.String()
+	res.body = errorBody(err)
+	res.Error = TTSError(responseError(CompletionError(res.Error), err))
+	return res, err
+}
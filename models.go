@@ -0,0 +1,48 @@
+package gollum
+
+import "strings"
+
+// contextWindows are the context sizes, in tokens, of common models.
+var contextWindows = map[string]int{
+	"gpt-4o":             128000,
+	"gpt-4o-mini":        128000,
+	"gpt-4.1":            1047576,
+	"gpt-4.1-mini":       1047576,
+	"gpt-4.1-nano":       1047576,
+	"gpt-4-turbo":        128000,
+	"gpt-4":              8192,
+	"gpt-3.5-turbo":      16385,
+	"o1":                 200000,
+	"o1-mini":            128000,
+	"o3-mini":            200000,
+	"gemini-1.5-flash":   1048576,
+	"gemini-1.5-pro":     2097152,
+	"gemini-2.0-flash":   1048576,
+	"anthropic.claude-3": 200000,
+	"amazon.nova-micro":  128000,
+	"amazon.nova-lite":   300000,
+	"amazon.nova-pro":    300000,
+	"llama3":             8192,
+	"llama3.1":           131072,
+	"llama3.2":           131072,
+	"mistral":            32768,
+}
+
+// ContextWindow returns the context size of model in tokens, using the
+// longest known prefix of the name, e.g. to window a chat with
+// message.Chat.Window before sending it.
+func ContextWindow(model string) (int, bool) {
+	if size, ok := contextWindows[model]; ok {
+		return size, true
+	}
+	best := ""
+	for known := range contextWindows {
+		if strings.HasPrefix(model, known) && len(known) > len(best) {
+			best = known
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return contextWindows[best], true
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/url"
 	"path"
@@ -31,25 +30,37 @@ type CompletionRequest struct {
 }
 
 type CompletionResponse struct {
-	Created            string  `json:"created_at"`
-	Model              string  `json:"model"`
-	Message            Message `json:"message"`
-	Done               bool    `json:"done"`
-	TotalDuration      int     `json:"total_duration"`
-	LoadDuration       int     `json:"load_duration"`
-	PromptEvalCount    int     `json:"prompt_eval_count"`
-	PromptEvalDuration int     `json:"prompt_eval_duration"`
-	EvalCount          int     `json:"eval_count"`
-	EvalDuration       int     `json:"eval_duration"`
-	Error              string  `json:"error,omitempty"`
-	StatusCode         int     `json:"status_code"`
+	Created            string      `json:"created_at"`
+	Model              string      `json:"model"`
+	Message            Message     `json:"message"`
+	Done               bool        `json:"done"`
+	DoneReason         string      `json:"done_reason,omitempty"`
+	TotalDuration      int         `json:"total_duration"`
+	LoadDuration       int         `json:"load_duration"`
+	PromptEvalCount    int         `json:"prompt_eval_count"`
+	PromptEvalDuration int         `json:"prompt_eval_duration"`
+	EvalCount          int         `json:"eval_count"`
+	EvalDuration       int         `json:"eval_duration"`
+	Error              string      `json:"error,omitempty"`
+	StatusCode         int         `json:"status_code"`
+	Header             http.Header `json:"-"`
+	body               []byte
 }
 
 func (or CompletionResponse) err() error {
-	if or.Error == "" {
+	if or.Error == "" && or.StatusCode < http.StatusBadRequest {
 		return nil
 	}
-	return errors.New(or.Error)
+	message := or.Error
+	if message == "" {
+		message = http.StatusText(or.StatusCode)
+	}
+	return &APIError{
+		StatusCode: or.StatusCode,
+		Message:    message,
+		Header:     or.Header,
+		Body:       or.body,
+	}
 }
 
 func makeHTTPCompletionRequest(request *CompletionRequest, oc OllamaClient) (*http.Response, error) {
@@ -71,8 +82,7 @@ func makeHTTPCompletionRequest(request *CompletionRequest, oc OllamaClient) (*ht
 		req = req.WithContext(request.Ctx)
 	}
 
-	client := http.Client{Timeout: oc.Timeout}
-	res, err := client.Do(req)
+	res, err := oc.Retry.Do(oc.httpClient(), req)
 
 	return res, err
 }
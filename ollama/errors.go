@@ -0,0 +1,29 @@
+package ollama
+
+import (
+	"net/http"
+)
+
+// APIError is returned when Ollama replies with an error payload or with a
+// non successful status code.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// statusError is used when an unsuccessful reply carries no error payload
+// that could be decoded.
+func statusError(res *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Message:    string(body),
+		Header:     res.Header,
+		Body:       body,
+	}
+}
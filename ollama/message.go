@@ -1,9 +1,13 @@
 package ollama
 
+import "encoding/json"
+
 type Message struct {
 	Role      string     `json:"role"`
 	Content   string     `json:"content"`
 	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	// ToolName is the tool whose output a tool message is.
+	ToolName string `json:"tool_name,omitempty"`
 }
 
 type toolCall struct {
@@ -13,6 +17,11 @@ type toolCall struct {
 }
 
 type toolCallFunc struct {
-	Name      string `json:"name"`
-	Arguments string `json:"arguments"`
+	Name string `json:"name"`
+	// Arguments is a JSON object, not a string as with OpenAI.
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+func NewToolCall(id, name string, arguments json.RawMessage) toolCall {
+	return toolCall{Id: id, Type: "function", Function: toolCallFunc{Name: name, Arguments: arguments}}
 }
@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/azr4e1/gollum/retry"
 )
 
 const (
@@ -26,6 +28,10 @@ type OllamaClient struct {
 	stream         bool
 	streamFunction StreamingFunction
 	Timeout        time.Duration
+	Retry          retry.Policy
+	// HTTPClient is used to send requests when set. Otherwise a client
+	// with Timeout and the default transport is used.
+	HTTPClient *http.Client
 }
 
 func NewClient(baseURL string) (OllamaClient, error) {
@@ -35,6 +41,13 @@ func NewClient(baseURL string) (OllamaClient, error) {
 	return OllamaClient{baseURL: baseURL, stream: false, Timeout: 30 * time.Second}, nil
 }
 
+func (oc OllamaClient) httpClient() *http.Client {
+	if oc.HTTPClient != nil {
+		return oc.HTTPClient
+	}
+	return &http.Client{Timeout: oc.Timeout}
+}
+
 func (oc *OllamaClient) EnableStream(function StreamingFunction) {
 	oc.stream = true
 	oc.streamFunction = function
@@ -68,11 +81,16 @@ func (oc OllamaClient) readCompletionResponse(res *http.Response) (CompletionRes
 	ollamaRes := new(CompletionResponse)
 	err = json.Unmarshal(body, ollamaRes)
 	if err != nil {
+		if res.StatusCode >= http.StatusBadRequest {
+			return CompletionResponse{StatusCode: res.StatusCode}, statusError(res, body)
+		}
 		return CompletionResponse{}, err
 	}
 
 	// attach status code to response object
 	ollamaRes.StatusCode = res.StatusCode
+	ollamaRes.Header = res.Header
+	ollamaRes.body = body
 
 	return *ollamaRes, ollamaRes.err()
 }
@@ -113,6 +131,7 @@ func (oc OllamaClient) readCompletionStreamResponse(res *http.Response) error {
 		}
 		// attach status code to response object
 		chunk.StatusCode = res.StatusCode
+		chunk.Header = res.Header
 
 		err = oc.streamFunction(*chunk)
 		if err != nil {
@@ -128,11 +147,13 @@ func (oc OllamaClient) readCompletionStreamResponse(res *http.Response) error {
 	ollamaRes := new(CompletionResponse)
 	err = json.Unmarshal(body, ollamaRes)
 	if err != nil {
-		return err
+		return statusError(res, body)
 	}
 
 	// attach status code to response object
 	ollamaRes.StatusCode = res.StatusCode
+	ollamaRes.Header = res.Header
+	ollamaRes.body = body
 
 	return ollamaRes.err()
 }
@@ -0,0 +1,160 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const batchesURL = "https://api.openai.com/v1/batches"
+
+// BatchCompletionURL is the url of the chat completions endpoint in batch
+// input files.
+const BatchCompletionURL = "/v1/chat/completions"
+
+// DefaultBatchPollInterval is the interval of WaitBatch when none is given.
+const DefaultBatchPollInterval = 30 * time.Second
+
+const (
+	BatchValidating = "validating"
+	BatchFailed     = "failed"
+	BatchInProgress = "in_progress"
+	BatchFinalizing = "finalizing"
+	BatchCompleted  = "completed"
+	BatchExpired    = "expired"
+	BatchCancelling = "cancelling"
+	BatchCancelled  = "cancelled"
+)
+
+// BatchRequestLine is a line of a batch input file.
+type BatchRequestLine struct {
+	CustomId string            `json:"custom_id"`
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Body     CompletionRequest `json:"body"`
+}
+
+// BatchResponseLine is a line of a batch output or error file. Requests
+// that got a reply have a Response, possibly with an error status, the
+// others an Error.
+type BatchResponseLine struct {
+	Id       string             `json:"id"`
+	CustomId string             `json:"custom_id"`
+	Response *BatchLineResponse `json:"response"`
+	Error    *CompletionError   `json:"error"`
+}
+
+type BatchLineResponse struct {
+	StatusCode int                `json:"status_code"`
+	RequestId  string             `json:"request_id"`
+	Body       CompletionResponse `json:"body"`
+}
+
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type BatchErrors struct {
+	Data []BatchError `json:"data"`
+}
+
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    *int   `json:"line,omitempty"`
+}
+
+type Batch struct {
+	Id               string             `json:"id"`
+	Object           string             `json:"object"`
+	Endpoint         string             `json:"endpoint"`
+	Errors           *BatchErrors       `json:"errors,omitempty"`
+	InputFileId      string             `json:"input_file_id"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	OutputFileId     string             `json:"output_file_id,omitempty"`
+	ErrorFileId      string             `json:"error_file_id,omitempty"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     int64              `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64              `json:"expires_at,omitempty"`
+	FinalizingAt     int64              `json:"finalizing_at,omitempty"`
+	CompletedAt      int64              `json:"completed_at,omitempty"`
+	FailedAt         int64              `json:"failed_at,omitempty"`
+	ExpiredAt        int64              `json:"expired_at,omitempty"`
+	CancellingAt     int64              `json:"cancelling_at,omitempty"`
+	CancelledAt      int64              `json:"cancelled_at,omitempty"`
+	RequestCounts    BatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+}
+
+// Done reports whether the batch reached a final status.
+func (b Batch) Done() bool {
+	switch b.Status {
+	case BatchFailed, BatchCompleted, BatchExpired, BatchCancelled:
+		return true
+	}
+	return false
+}
+
+type createBatchRequest struct {
+	InputFileId      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// CreateBatch starts a batch of the requests in the uploaded file
+// inputFileId. window is the completion window, "24h" when empty.
+func (oc OpenaiClient) CreateBatch(ctx context.Context, inputFileId, window string, metadata map[string]string) (Batch, error) {
+	if window == "" {
+		window = "24h"
+	}
+	request := createBatchRequest{
+		InputFileId:      inputFileId,
+		Endpoint:         BatchCompletionURL,
+		CompletionWindow: window,
+		Metadata:         metadata,
+	}
+	batch := Batch{}
+	err := oc.doJSON(ctx, http.MethodPost, batchesURL, request, &batch)
+	return batch, err
+}
+
+func (oc OpenaiClient) RetrieveBatch(ctx context.Context, id string) (Batch, error) {
+	batch := Batch{}
+	err := oc.doJSON(ctx, http.MethodGet, batchesURL+"/"+id, nil, &batch)
+	return batch, err
+}
+
+func (oc OpenaiClient) CancelBatch(ctx context.Context, id string) (Batch, error) {
+	batch := Batch{}
+	err := oc.doJSON(ctx, http.MethodPost, batchesURL+"/"+id+"/cancel", nil, &batch)
+	return batch, err
+}
+
+// WaitBatch polls batch id every interval until it reaches a final status
+// or ctx is done. An interval <= 0 polls every DefaultBatchPollInterval.
+func (oc OpenaiClient) WaitBatch(ctx context.Context, id string, interval time.Duration) (Batch, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if interval <= 0 {
+		interval = DefaultBatchPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		batch, err := oc.RetrieveBatch(ctx, id)
+		if err != nil || batch.Done() {
+			return batch, err
+		}
+		select {
+		case <-ctx.Done():
+			return batch, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
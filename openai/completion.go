@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 )
@@ -46,6 +45,7 @@ type CompletionChoice struct {
 type CompletionError struct {
 	Message string `json:"message"`
 	Type    string `json:"type"`
+	Code    string `json:"code"`
 }
 
 type CompletionResponse struct {
@@ -57,13 +57,22 @@ type CompletionResponse struct {
 	Usage      CompletionUsage    `json:"usage"`
 	Error      CompletionError    `json:"error,omitempty"`
 	StatusCode int                `json:"status_code"`
+	Header     http.Header        `json:"-"`
+	body       []byte
 }
 
 func (or CompletionResponse) err() error {
-	if or.Error.Type == "" && or.Error.Message == "" {
+	if or.Error.Type == "" && or.Error.Message == "" && or.StatusCode < http.StatusBadRequest {
 		return nil
 	}
-	return errors.New(fmt.Sprintf("%s: %s", or.Error.Type, or.Error.Message))
+	return &APIError{
+		StatusCode: or.StatusCode,
+		Type:       or.Error.Type,
+		Code:       or.Error.Code,
+		Message:    or.Error.Message,
+		Header:     or.Header,
+		Body:       or.body,
+	}
 }
 
 func makeHTTPCompletionRequest(request *CompletionRequest, oc OpenaiClient) (*http.Response, error) {
@@ -83,8 +92,7 @@ func makeHTTPCompletionRequest(request *CompletionRequest, oc OpenaiClient) (*ht
 		req = req.WithContext(request.Ctx)
 	}
 
-	client := http.Client{Timeout: oc.Timeout}
-	res, err := client.Do(req)
+	res, err := oc.Retry.Do(oc.httpClient(), req)
 
 	return res, err
 }
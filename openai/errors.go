@@ -0,0 +1,33 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIError is returned when OpenAI replies with an error payload or with a
+// non successful status code.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// statusError is used when an unsuccessful reply carries no error payload
+// that could be decoded.
+func statusError(res *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Type:       http.StatusText(res.StatusCode),
+		Message:    string(body),
+		Header:     res.Header,
+		Body:       body,
+	}
+}
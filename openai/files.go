@@ -0,0 +1,130 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const filesURL = "https://api.openai.com/v1/files"
+
+// File purposes.
+const (
+	PurposeBatch      = "batch"
+	PurposeFineTune   = "fine-tune"
+	PurposeAssistants = "assistants"
+	PurposeVision     = "vision"
+	PurposeUserData   = "user_data"
+)
+
+type File struct {
+	Id        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+type fileList struct {
+	Data    []File `json:"data"`
+	HasMore bool   `json:"has_more"`
+}
+
+type deletedFile struct {
+	Id      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+}
+
+// filesPageSize is the largest page the files endpoint returns.
+const filesPageSize = 10000
+
+// UploadFile uploads the content of r as a file named filename, for
+// purpose. The content is streamed to the request as it is read, so large
+// files are never held in memory, and the upload is not retried. The client
+// Timeout does not apply, so that large uploads can finish: ctx cancels it.
+func (oc OpenaiClient) UploadFile(ctx context.Context, filename, purpose string, r io.Reader) (File, error) {
+	body, pipe := io.Pipe()
+	// closing the reading end stops the writer if the request ends early
+	defer body.Close()
+	writer := multipart.NewWriter(pipe)
+	contentType := writer.FormDataContentType()
+	go func() {
+		pipe.CloseWithError(writeUpload(writer, filename, purpose, r))
+	}()
+
+	res, err := oc.send(ctx, oc.transferClient(), http.MethodPost, filesURL, contentType, body)
+	if err != nil {
+		return File{}, err
+	}
+	defer res.Body.Close()
+
+	file := File{}
+	err = json.NewDecoder(res.Body).Decode(&file)
+	return file, err
+}
+
+func writeUpload(writer *multipart.Writer, filename, purpose string, r io.Reader) error {
+	err := writer.WriteField("purpose", purpose)
+	if err != nil {
+		return err
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, r)
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// ListFiles returns the uploaded files with purpose, or all of them when
+// purpose is empty.
+func (oc OpenaiClient) ListFiles(ctx context.Context, purpose string) ([]File, error) {
+	files := []File{}
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(filesPageSize))
+	if purpose != "" {
+		query.Set("purpose", purpose)
+	}
+	for {
+		page := fileList{}
+		err := oc.doJSON(ctx, http.MethodGet, filesURL+"?"+query.Encode(), nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page.Data...)
+		if !page.HasMore || len(page.Data) == 0 {
+			return files, nil
+		}
+		query.Set("after", page.Data[len(page.Data)-1].Id)
+	}
+}
+
+func (oc OpenaiClient) RetrieveFile(ctx context.Context, id string) (File, error) {
+	file := File{}
+	err := oc.doJSON(ctx, http.MethodGet, filesURL+"/"+url.PathEscape(id), nil, &file)
+	return file, err
+}
+
+func (oc OpenaiClient) DeleteFile(ctx context.Context, id string) error {
+	deleted := deletedFile{}
+	return oc.doJSON(ctx, http.MethodDelete, filesURL+"/"+url.PathEscape(id), nil, &deleted)
+}
+
+// FileContent returns the content of file id. The caller closes it. Like
+// UploadFile, the download is only bounded by ctx.
+func (oc OpenaiClient) FileContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	res, err := oc.send(ctx, oc.transferClient(), http.MethodGet, filesURL+"/"+url.PathEscape(id)+"/content", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
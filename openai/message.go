@@ -1,12 +1,16 @@
 package openai
 
 type Message struct {
-	Role      string     `json:"role"`
-	Content   string     `json:"content"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallId string     `json:"tool_call_id,omitempty"`
 }
 
 type ToolCall struct {
+	// Index is only set in stream deltas, where the arguments of a call
+	// are spread over the deltas with the same index.
+	Index    *int         `json:"index,omitempty"`
 	Id       string       `json:"id"`
 	Type     string       `json:"type"`
 	Function toolCallFunc `json:"function"`
@@ -16,3 +20,7 @@ type toolCallFunc struct {
 	Name      string `json:"name"`
 	Arguments string `json:"arguments"`
 }
+
+func NewToolCall(id, name, arguments string) ToolCall {
+	return ToolCall{Id: id, Type: "function", Function: toolCallFunc{Name: name, Arguments: arguments}}
+}
@@ -3,11 +3,15 @@ package openai
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/azr4e1/gollum/retry"
 )
 
 const (
@@ -27,6 +31,10 @@ type OpenaiClient struct {
 	stream         bool
 	streamFunction StreamingFunction
 	Timeout        time.Duration
+	Retry          retry.Policy
+	// HTTPClient is used to send requests when set. Otherwise a client
+	// with Timeout and the default transport is used.
+	HTTPClient *http.Client
 }
 
 func NewClient(apiKey string) (OpenaiClient, error) {
@@ -36,6 +44,98 @@ func NewClient(apiKey string) (OpenaiClient, error) {
 	return OpenaiClient{apiKey: apiKey, Timeout: 30 * time.Second}, nil
 }
 
+func (oc OpenaiClient) httpClient() *http.Client {
+	if oc.HTTPClient != nil {
+		return oc.HTTPClient
+	}
+	return &http.Client{Timeout: oc.Timeout}
+}
+
+// transferClient is used for file uploads and downloads. Their Timeout
+// would cover the whole body, so it is removed and only their context ends
+// them.
+func (oc OpenaiClient) transferClient() *http.Client {
+	client := *oc.httpClient()
+	client.Timeout = 0
+	return &client
+}
+
+// do sends an API request and returns the response when it is successful.
+// The caller closes its body.
+func (oc OpenaiClient) do(ctx context.Context, method, url, contentType string, body io.Reader) (*http.Response, error) {
+	return oc.send(ctx, oc.httpClient(), method, url, contentType, body)
+}
+
+// send is do with client. A body that is not a bytes.Reader, such as a
+// streamed upload, cannot be sent again and is not retried.
+func (oc OpenaiClient) send(ctx context.Context, client *http.Client, method, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", oc.apiKey))
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+
+	res, err := oc.Retry.Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		return nil, readError(res)
+	}
+	return res, nil
+}
+
+// doJSON sends an API request with a JSON body, when request is not nil,
+// and decodes the JSON reply into response.
+func (oc OpenaiClient) doJSON(ctx context.Context, method, url string, request, response any) error {
+	var body io.Reader
+	contentType := ""
+	if request != nil {
+		jsonRequest, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(jsonRequest)
+		contentType = "application/json"
+	}
+
+	res, err := oc.do(ctx, method, url, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(response)
+}
+
+func readError(res *http.Response) error {
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	payload := struct {
+		Error CompletionError `json:"error"`
+	}{}
+	if json.Unmarshal(body, &payload) != nil || payload.Error.Message == "" {
+		return statusError(res, body)
+	}
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Type:       payload.Error.Type,
+		Code:       payload.Error.Code,
+		Message:    payload.Error.Message,
+		Header:     res.Header,
+		Body:       body,
+	}
+}
+
 func (oc *OpenaiClient) EnableStream(function StreamingFunction) {
 	oc.stream = true
 	oc.streamFunction = function
@@ -69,11 +169,16 @@ func (oc OpenaiClient) readCompletionResponse(res *http.Response) (CompletionRes
 	openaiRes := new(CompletionResponse)
 	err = json.Unmarshal(body, openaiRes)
 	if err != nil {
+		if res.StatusCode >= http.StatusBadRequest {
+			return CompletionResponse{StatusCode: res.StatusCode}, statusError(res, body)
+		}
 		return CompletionResponse{}, err
 	}
 
 	// attach status code to response object
 	openaiRes.StatusCode = res.StatusCode
+	openaiRes.Header = res.Header
+	openaiRes.body = body
 
 	return *openaiRes, openaiRes.err()
 }
@@ -114,6 +219,7 @@ func (oc OpenaiClient) readCompletionStreamResponse(res *http.Response) error {
 		}
 		// attach status code to response object
 		chunk.StatusCode = res.StatusCode
+		chunk.Header = res.Header
 
 		err = oc.streamFunction(*chunk)
 		if err != nil {
@@ -129,11 +235,13 @@ func (oc OpenaiClient) readCompletionStreamResponse(res *http.Response) error {
 	openaiRes := new(CompletionResponse)
 	err = json.Unmarshal(body, openaiRes)
 	if err != nil {
-		return err
+		return statusError(res, body)
 	}
 
 	// attach status code to response object
 	openaiRes.StatusCode = res.StatusCode
+	openaiRes.Header = res.Header
+	openaiRes.body = body
 
 	return openaiRes.err()
 }
@@ -152,18 +260,20 @@ func (oc OpenaiClient) TextToSpeech(request *TTSRequest) (TTSRequest, TTSRespons
 		return *request, *response, err
 	}
 
+	response.StatusCode = res.StatusCode
+	response.Header = res.Header
+
 	// check the return status
 	if res.StatusCode != http.StatusOK {
 		err := json.Unmarshal(body, response)
 		if err != nil {
-			return *request, *response, err
+			return *request, *response, statusError(res, body)
 		}
 
-		response.StatusCode = res.StatusCode
+		response.body = body
 		return *request, *response, response.Err()
 	}
 
-	response.StatusCode = res.StatusCode
 	response.Audio = body
 	return *request, *response, response.Err()
 }
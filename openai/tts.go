@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 )
@@ -47,22 +46,32 @@ type TTSRequest struct {
 }
 
 type TTSResponse struct {
-	Audio      []byte   `json:"audio"`
-	Error      TTSError `json:"error,omitempty"`
-	StatusCode int      `json:"status_code"`
+	Audio      []byte      `json:"audio"`
+	Error      TTSError    `json:"error,omitempty"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"-"`
+	body       []byte
 }
 
 func (ttsr TTSResponse) Err() error {
-	if ttsr.Error.Type == "" && ttsr.Error.Message == "" {
+	if ttsr.Error.Type == "" && ttsr.Error.Message == "" && ttsr.StatusCode < http.StatusBadRequest {
 		return nil
 	}
 
-	return errors.New(fmt.Sprintf("%s: %s", ttsr.Error.Type, ttsr.Error.Message))
+	return &APIError{
+		StatusCode: ttsr.StatusCode,
+		Type:       ttsr.Error.Type,
+		Code:       ttsr.Error.Code,
+		Message:    ttsr.Error.Message,
+		Header:     ttsr.Header,
+		Body:       ttsr.body,
+	}
 }
 
 type TTSError struct {
 	Message string `json:"message"`
 	Type    string `json:"type"`
+	Code    string `json:"code"`
 }
 
 func makeHTTPTTSRequest(request *TTSRequest, oc OpenaiClient) (*http.Response, error) {
@@ -82,8 +91,7 @@ func makeHTTPTTSRequest(request *TTSRequest, oc OpenaiClient) (*http.Response, e
 		req = req.WithContext(request.Ctx)
 	}
 
-	client := http.Client{Timeout: oc.Timeout}
-	res, err := client.Do(req)
+	res, err := oc.Retry.Do(oc.httpClient(), req)
 
 	return res, err
 }
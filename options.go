@@ -3,7 +3,12 @@ package gollum
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
 	m "github.com/azr4e1/gollum/message"
+	"github.com/azr4e1/gollum/retry"
 )
 
 type clientOption func(*LLMClient) error
@@ -35,6 +40,244 @@ func WithAPIBase(apiBase string) clientOption {
 	}
 }
 
+func WithRegion(region string) clientOption {
+	return func(lc *LLMClient) error {
+		if region == "" {
+			return errors.New("must provide region.")
+		}
+		lc.region = region
+
+		return nil
+	}
+}
+
+// WithHTTPClient sends all requests through client, e.g. to use a proxy or
+// custom TLS configuration.
+func WithHTTPClient(client *http.Client) clientOption {
+	return func(lc *LLMClient) error {
+		if client == nil {
+			return errors.New("http client cannot be nil.")
+		}
+		lc.client = client
+
+		return nil
+	}
+}
+
+// WithTransport sends all requests through transport, e.g. to add tracing
+// or to replace the network in tests.
+func WithTransport(transport http.RoundTripper) clientOption {
+	return func(lc *LLMClient) error {
+		if transport == nil {
+			return errors.New("transport cannot be nil.")
+		}
+		lc.transport = transport
+
+		return nil
+	}
+}
+
+// WithRetry retries failed requests up to maxAttempts times in total, with
+// an exponential backoff starting at baseDelay and capped at maxDelay. jitter
+// is the fraction of each delay, between 0 and 1, that is randomized.
+func WithRetry(maxAttempts int, baseDelay, maxDelay time.Duration, jitter float64) clientOption {
+	return func(lc *LLMClient) error {
+		if maxAttempts < 1 {
+			return errors.New("max attempts must be at least 1.")
+		}
+		if baseDelay < 0 || maxDelay < 0 {
+			return errors.New("retry delays cannot be negative.")
+		}
+		if jitter < 0 || jitter > 1 {
+			return errors.New("jitter must be between 0 and 1.")
+		}
+		lc.retry = retry.Policy{
+			MaxAttempts: maxAttempts,
+			BaseDelay:   baseDelay,
+			MaxDelay:    maxDelay,
+			Jitter:      jitter,
+		}
+
+		return nil
+	}
+}
+
+// WithRateLimit throttles Complete to the given requests and tokens per
+// minute for each model. Use WithRateLimiter to share limits between
+// clients.
+func WithRateLimit(requestsPerMinute, tokensPerMinute int) clientOption {
+	return func(lc *LLMClient) error {
+		limiter, err := NewRateLimiter(requestsPerMinute, tokensPerMinute)
+		if err != nil {
+			return err
+		}
+		lc.limiter = limiter
+
+		return nil
+	}
+}
+
+func WithRateLimiter(limiter *RateLimiter) clientOption {
+	return func(lc *LLMClient) error {
+		if limiter == nil {
+			return errors.New("rate limiter cannot be nil.")
+		}
+		lc.limiter = limiter
+
+		return nil
+	}
+}
+
+// WithMiddleware adds middleware around every Complete call. The first
+// middleware is the outermost one.
+func WithMiddleware(middleware ...Middleware) clientOption {
+	return func(lc *LLMClient) error {
+		for _, mw := range middleware {
+			if mw == nil {
+				return errors.New("middleware cannot be nil.")
+			}
+		}
+		lc.middleware = append(lc.middleware, middleware...)
+
+		return nil
+	}
+}
+
+// WithLogger logs the metadata, latency, status and token usage of every
+// completion at debug level, and failures at error level. The API key is
+// never logged.
+func WithLogger(logger *slog.Logger) clientOption {
+	return func(lc *LLMClient) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil.")
+		}
+		lc.logger = logger
+
+		return nil
+	}
+}
+
+// WithBodyLogging additionally logs the full HTTP request and response
+// bodies at debug level. It requires WithLogger.
+func WithBodyLogging() clientOption {
+	return func(lc *LLMClient) error {
+		lc.logBodies = true
+
+		return nil
+	}
+}
+
+// WithContentRedaction passes message contents through redact before they
+// are logged. A nil redact uses RedactContent.
+func WithContentRedaction(redact func(string) string) clientOption {
+	return func(lc *LLMClient) error {
+		if redact == nil {
+			redact = RedactContent
+		}
+		lc.redactContent = redact
+
+		return nil
+	}
+}
+
+// WithSpeechMiddleware adds middleware around every TextToSpeech call. The
+// first middleware is the outermost one.
+func WithSpeechMiddleware(middleware ...SpeechMiddleware) clientOption {
+	return func(lc *LLMClient) error {
+		for _, mw := range middleware {
+			if mw == nil {
+				return errors.New("middleware cannot be nil.")
+			}
+		}
+		lc.speechMiddleware = append(lc.speechMiddleware, middleware...)
+
+		return nil
+	}
+}
+
+// WithMetrics reports the latency, status, token usage and errors of every
+// completion to collector.
+func WithMetrics(collector MetricsCollector) clientOption {
+	return func(lc *LLMClient) error {
+		if collector == nil {
+			return errors.New("metrics collector cannot be nil.")
+		}
+		lc.metrics = collector
+
+		return nil
+	}
+}
+
+// WithCostTracker charges the cost of every completion to tracker.
+func WithCostTracker(tracker *CostTracker) clientOption {
+	return func(lc *LLMClient) error {
+		if tracker == nil {
+			return errors.New("cost tracker cannot be nil.")
+		}
+		lc.costs = tracker
+
+		return nil
+	}
+}
+
+// WithBudget refuses new completions with ErrBudgetExceeded once budget has
+// been spent. Costs are computed with the cost tracker of the client, or
+// the default prices when there is none.
+func WithBudget(budget *Budget) clientOption {
+	return func(lc *LLMClient) error {
+		if budget == nil {
+			return errors.New("budget cannot be nil.")
+		}
+		lc.budget = budget
+
+		return nil
+	}
+}
+
+// WithCache answers identical requests from cache. Entries expire after
+// ttl, or never when ttl is 0.
+func WithCache(cache Cache, ttl time.Duration) clientOption {
+	return func(lc *LLMClient) error {
+		if cache == nil {
+			return errors.New("cache cannot be nil.")
+		}
+		if ttl < 0 {
+			return errors.New("cache ttl cannot be negative.")
+		}
+		lc.cache = cache
+		lc.cacheTTL = ttl
+
+		return nil
+	}
+}
+
+// WithSemanticCache answers near duplicate questions from cache. It is
+// consulted after the exact cache of WithCache.
+func WithSemanticCache(cache *SemanticCache) clientOption {
+	return func(lc *LLMClient) error {
+		if cache == nil {
+			return errors.New("semantic cache cannot be nil.")
+		}
+		lc.semanticCache = cache
+
+		return nil
+	}
+}
+
+// WithCircuitBreaker fails requests fast with ErrCircuitOpen after
+// failures consecutive provider failures, for cooldown.
+func WithCircuitBreaker(failures int, cooldown time.Duration) clientOption {
+	return func(lc *LLMClient) error {
+		breaker, err := NewCircuitBreaker(failures, cooldown)
+		if err != nil {
+			return err
+		}
+		lc.breaker = breaker
+
+		return nil
+	}
+}
+
 func WithModel(modelName string) completionOption {
 	return func(oR *CompletionRequest) error {
 		oR.Model = modelName
@@ -48,7 +291,7 @@ func WithChat(chat m.Chat) completionOption {
 		if chat.IsEmpty() {
 			return errors.New("Missing messages to send.")
 		}
-		oR.System = chat.SystemMessage()
+		oR.System = systemWithSummary(chat)
 		oR.Messages = chat.History()
 
 		return nil
@@ -198,6 +441,16 @@ func WithContext(ctx context.Context) completionOption {
 	}
 }
 
+// WithCacheBypass sends the request to the provider even when a cached
+// response exists, and refreshes the cache with the new response.
+func WithCacheBypass() completionOption {
+	return func(oR *CompletionRequest) error {
+		oR.CacheBypass = true
+
+		return nil
+	}
+}
+
 func WithTool(tools ...Tool) completionOption {
 	return func(oR *CompletionRequest) error {
 		oR.Tools = tools
@@ -0,0 +1,339 @@
+// Package otel traces gollum completions, text to speech requests and tool
+// executions with OpenTelemetry, following the GenAI semantic conventions.
+//
+// It lives in its own module so that the core library does not depend on
+// OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/azr4e1/gollum"
+	m "github.com/azr4e1/gollum/message"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/azr4e1/gollum/otel"
+
+// attribute keys from the GenAI semantic conventions
+const (
+	operationName      = attribute.Key("gen_ai.operation.name")
+	system             = attribute.Key("gen_ai.system")
+	requestModel       = attribute.Key("gen_ai.request.model")
+	requestMaxTokens   = attribute.Key("gen_ai.request.max_tokens")
+	requestTemperature = attribute.Key("gen_ai.request.temperature")
+	requestTopP        = attribute.Key("gen_ai.request.top_p")
+	requestTopK        = attribute.Key("gen_ai.request.top_k")
+	requestFreqPenalty = attribute.Key("gen_ai.request.frequency_penalty")
+	requestPresPenalty = attribute.Key("gen_ai.request.presence_penalty")
+	requestStop        = attribute.Key("gen_ai.request.stop_sequences")
+	requestSeed        = attribute.Key("gen_ai.request.seed")
+	responseID         = attribute.Key("gen_ai.response.id")
+	responseModel      = attribute.Key("gen_ai.response.model")
+	responseFinish     = attribute.Key("gen_ai.response.finish_reasons")
+	usageInputTokens   = attribute.Key("gen_ai.usage.input_tokens")
+	usageOutputTokens  = attribute.Key("gen_ai.usage.output_tokens")
+	toolName           = attribute.Key("gen_ai.tool.name")
+	toolCallID         = attribute.Key("gen_ai.tool.call.id")
+	errorType          = attribute.Key("error.type")
+	exceptionType      = attribute.Key("exception.type")
+	exceptionMessage   = attribute.Key("exception.message")
+	httpStatusCode     = attribute.Key("http.response.status_code")
+	speechVoice        = attribute.Key("gollum.tts.voice")
+	speechFormat       = attribute.Key("gollum.tts.format")
+	stepName           = attribute.Key("gollum.step.name")
+	operationChat      = "chat"
+	operationSpeech    = "text_to_speech"
+	operationTool      = "execute_tool"
+	operationAgentStep = "invoke_agent"
+	otherErrorType     = "_OTHER"
+)
+
+// systems maps the gollum provider names to gen_ai.system values.
+var systems = map[string]string{
+	"openai":  "openai",
+	"gemini":  "gcp.gemini",
+	"bedrock": "aws.bedrock",
+	"ollama":  "ollama",
+}
+
+type config struct {
+	tracer trace.Tracer
+}
+
+type Option func(*config)
+
+// WithTracerProvider uses tp instead of the global tracer provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{}
+	for _, o := range opts {
+		o(&c)
+	}
+	if c.tracer == nil {
+		c.tracer = otelapi.GetTracerProvider().Tracer(instrumentationName)
+	}
+	return c
+}
+
+// Middleware creates a client span for every completion. The span context
+// is passed down through the request context.
+func Middleware(opts ...Option) gollum.Middleware {
+	c := newConfig(opts)
+
+	return func(next gollum.CompleteFunc) gollum.CompleteFunc {
+		return func(request gollum.CompletionRequest, stream gollum.StreamingFunction) (gollum.CompletionResponse, error) {
+			ctx, span := c.tracer.Start(contextOf(request.Ctx), spanName(operationChat, request.Model),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(requestAttributes(request)...),
+			)
+			defer span.End()
+			request.Ctx = ctx
+
+			// streamed responses are spread over the chunks
+			var last gollum.CompletionResponse
+			if stream != nil {
+				streamFunction := stream
+				first := true
+				stream = func(res gollum.CompletionResponse) error {
+					if first {
+						first = false
+						span.AddEvent("gen_ai.content.first_chunk")
+					}
+					mergeChunk(&last, res)
+					return streamFunction(res)
+				}
+			}
+
+			res, err := next(request, stream)
+			if stream == nil {
+				last = res
+			}
+			span.SetAttributes(responseAttributes(last)...)
+			if err != nil {
+				recordError(span, err)
+			}
+
+			return res, err
+		}
+	}
+}
+
+// SpeechMiddleware creates a client span for every text to speech request.
+func SpeechMiddleware(opts ...Option) gollum.SpeechMiddleware {
+	c := newConfig(opts)
+
+	return func(next gollum.SpeechFunc) gollum.SpeechFunc {
+		return func(request gollum.TTSRequest) (gollum.TTSResponse, error) {
+			attrs := []attribute.KeyValue{
+				operationName.String(operationSpeech),
+				requestModel.String(request.Model),
+				speechVoice.String(request.Voice),
+			}
+			if request.Format != "" {
+				attrs = append(attrs, speechFormat.String(request.Format))
+			}
+			ctx, span := c.tracer.Start(contextOf(request.Ctx), spanName(operationSpeech, request.Model),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+			request.Ctx = ctx
+
+			res, err := next(request)
+			if s, ok := systems[res.Provider]; ok {
+				span.SetAttributes(system.String(s))
+			}
+			if res.StatusCode != 0 {
+				span.SetAttributes(httpStatusCode.Int(res.StatusCode))
+			}
+			if err != nil {
+				recordError(span, err)
+			}
+
+			return res, err
+		}
+	}
+}
+
+// TraceTool runs a tool call inside an execute_tool span, as a child of the
+// span in ctx.
+func TraceTool(ctx context.Context, call m.ToolCall, run func(context.Context) (string, error), opts ...Option) (string, error) {
+	c := newConfig(opts)
+	ctx, span := c.tracer.Start(contextOf(ctx), spanName(operationTool, call.Name),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			operationName.String(operationTool),
+			toolName.String(call.Name),
+			toolCallID.String(call.Id),
+		),
+	)
+	defer span.End()
+
+	output, err := run(ctx)
+	if err != nil {
+		recordError(span, err)
+	}
+	return output, err
+}
+
+// StartStep starts a span for one step of an agent loop. Completions and
+// tool calls made with the returned context become its children. The
+// caller must end the span.
+func StartStep(ctx context.Context, name string, opts ...Option) (context.Context, trace.Span) {
+	c := newConfig(opts)
+	return c.tracer.Start(contextOf(ctx), spanName(operationAgentStep, name),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(operationName.String(operationAgentStep), stepName.String(name)),
+	)
+}
+
+func contextOf(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+func spanName(operation, target string) string {
+	if target == "" {
+		return operation
+	}
+	return operation + " " + target
+}
+
+func requestAttributes(request gollum.CompletionRequest) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		operationName.String(operationChat),
+		requestModel.String(request.Model),
+	}
+	if v := request.MaxCompletionTokens; v != nil {
+		attrs = append(attrs, requestMaxTokens.Int(*v))
+	}
+	if v := request.Temperature; v != nil {
+		attrs = append(attrs, requestTemperature.Float64(*v))
+	}
+	if v := request.TopP; v != nil {
+		attrs = append(attrs, requestTopP.Float64(*v))
+	}
+	if v := request.TopK; v != nil {
+		attrs = append(attrs, requestTopK.Int(*v))
+	}
+	if v := request.FreqPenalty; v != nil {
+		attrs = append(attrs, requestFreqPenalty.Float64(*v))
+	}
+	if v := request.PresencePenalty; v != nil {
+		attrs = append(attrs, requestPresPenalty.Float64(*v))
+	}
+	if v := request.Seed; v != nil {
+		attrs = append(attrs, requestSeed.Int(*v))
+	}
+	if len(request.Stop) > 0 {
+		attrs = append(attrs, requestStop.StringSlice(request.Stop))
+	}
+	return attrs
+}
+
+func responseAttributes(res gollum.CompletionResponse) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+	if s, ok := systems[res.Provider]; ok {
+		attrs = append(attrs, system.String(s))
+	}
+	if res.Id != "" {
+		attrs = append(attrs, responseID.String(res.Id))
+	}
+	if res.Model != "" {
+		attrs = append(attrs, responseModel.String(res.Model))
+	}
+	if res.FinishReason != "" {
+		attrs = append(attrs, responseFinish.StringSlice([]string{res.FinishReason}))
+	}
+	if res.StatusCode != 0 {
+		attrs = append(attrs, httpStatusCode.Int(res.StatusCode))
+	}
+	if res.Usage.PromptTokens != 0 || res.Usage.CompletionTokens != 0 {
+		attrs = append(attrs,
+			usageInputTokens.Int(res.Usage.PromptTokens),
+			usageOutputTokens.Int(res.Usage.CompletionTokens),
+		)
+	}
+	return attrs
+}
+
+// mergeChunk keeps the last non empty value of every field that is
+// reported on the span.
+func mergeChunk(last *gollum.CompletionResponse, chunk gollum.CompletionResponse) {
+	if chunk.Provider != "" {
+		last.Provider = chunk.Provider
+	}
+	if chunk.Id != "" {
+		last.Id = chunk.Id
+	}
+	if chunk.Model != "" {
+		last.Model = chunk.Model
+	}
+	if chunk.FinishReason != "" {
+		last.FinishReason = chunk.FinishReason
+	}
+	if chunk.StatusCode != 0 {
+		last.StatusCode = chunk.StatusCode
+	}
+	if chunk.Usage.TotalTokens != 0 || chunk.Usage.PromptTokens != 0 || chunk.Usage.CompletionTokens != 0 {
+		last.Usage = chunk.Usage
+	}
+}
+
+func recordError(span trace.Span, err error) {
+	kind := otherErrorType
+	var apiErr *gollum.APIError
+	if errors.As(err, &apiErr) {
+		if s, ok := systems[apiErr.Provider]; ok {
+			span.SetAttributes(system.String(s))
+		}
+		if apiErr.StatusCode != 0 {
+			span.SetAttributes(httpStatusCode.Int(apiErr.StatusCode))
+		}
+		switch {
+		case apiErr.Code != "":
+			kind = apiErr.Code
+		case apiErr.Type != "":
+			kind = apiErr.Type
+		case apiErr.StatusCode != 0:
+			kind = strconv.Itoa(apiErr.StatusCode)
+		}
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		kind = "timeout"
+	} else if errors.Is(err, context.Canceled) {
+		kind = "canceled"
+	} else {
+		kind = fmt.Sprintf("%T", err)
+	}
+	// recorded like span.RecordError, with the secrets removed
+	message := redactSecrets(err.Error())
+	span.SetAttributes(errorType.String(kind))
+	span.AddEvent("exception", trace.WithAttributes(
+		exceptionType.String(fmt.Sprintf("%T", err)),
+		exceptionMessage.String(message),
+	))
+	span.SetStatus(codes.Error, message)
+}
+
+// secretParams matches the query parameters carrying credentials, e.g. the
+// Gemini API key in the URL of a transport error.
+var secretParams = regexp.MustCompile(`([?&](?:key|api_key|X-Amz-Security-Token|X-Amz-Signature|X-Amz-Credential)=)[^&\s"']*`)
+
+func redactSecrets(s string) string {
+	return secretParams.ReplaceAllString(s, "${1}[REDACTED]")
+}
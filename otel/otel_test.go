@@ -0,0 +1,312 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/azr4e1/gollum"
+	m "github.com/azr4e1/gollum/message"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder() (*tracetest.SpanRecorder, Option) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return recorder, WithTracerProvider(tp)
+}
+
+func onlySpan(t *testing.T, recorder *tracetest.SpanRecorder) sdktrace.ReadOnlySpan {
+	t.Helper()
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	return spans[0]
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func checkAttributes(t *testing.T, span sdktrace.ReadOnlySpan, want ...attribute.KeyValue) {
+	t.Helper()
+	attrs := attributes(span)
+	for _, kv := range want {
+		got, ok := attrs[kv.Key]
+		if !ok {
+			t.Errorf("missing attribute %s", kv.Key)
+			continue
+		}
+		if got.Emit() != kv.Value.Emit() {
+			t.Errorf("%s = %s, want %s", kv.Key, got.Emit(), kv.Value.Emit())
+		}
+	}
+}
+
+func TestMiddlewareCompletion(t *testing.T) {
+	recorder, opt := newRecorder()
+	temperature := 0.2
+	maxTokens := 64
+	request := gollum.CompletionRequest{
+		Model:               "gpt-4o-mini",
+		Temperature:         &temperature,
+		MaxCompletionTokens: &maxTokens,
+		Stop:                []string{"\n"},
+	}
+
+	var inner trace.SpanContext
+	complete := Middleware(opt)(func(request gollum.CompletionRequest, stream gollum.StreamingFunction) (gollum.CompletionResponse, error) {
+		inner = trace.SpanContextFromContext(request.Ctx)
+		return gollum.CompletionResponse{
+			Id:           "chatcmpl-1",
+			Model:        "gpt-4o-mini-2024-07-18",
+			FinishReason: "stop",
+			StatusCode:   http.StatusOK,
+			Provider:     "openai",
+			Usage:        gollum.CompletionUsage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15},
+		}, nil
+	})
+	_, err := complete(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := onlySpan(t, recorder)
+	if span.Name() != "chat gpt-4o-mini" || span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("span = %q, kind %s", span.Name(), span.SpanKind())
+	}
+	if inner.SpanID() != span.SpanContext().SpanID() {
+		t.Error("the span is not passed down in the request context")
+	}
+	if span.Status().Code != codes.Unset {
+		t.Errorf("status = %+v", span.Status())
+	}
+	checkAttributes(t, span,
+		operationName.String(operationChat),
+		system.String("openai"),
+		requestModel.String("gpt-4o-mini"),
+		requestTemperature.Float64(0.2),
+		requestMaxTokens.Int(64),
+		requestStop.StringSlice([]string{"\n"}),
+		responseID.String("chatcmpl-1"),
+		responseModel.String("gpt-4o-mini-2024-07-18"),
+		responseFinish.StringSlice([]string{"stop"}),
+		httpStatusCode.Int(http.StatusOK),
+		usageInputTokens.Int(12),
+		usageOutputTokens.Int(3),
+	)
+	if _, ok := attributes(span)[requestTopP]; ok {
+		t.Error("unset parameters must not be recorded")
+	}
+}
+
+func TestMiddlewareStream(t *testing.T) {
+	recorder, opt := newRecorder()
+	chunks := []gollum.CompletionResponse{
+		{Id: "chatcmpl-2", Model: "gpt-4o-mini", Provider: "openai", StatusCode: http.StatusOK, Message: m.AssistantMessage("Hel")},
+		{Message: m.AssistantMessage("lo")},
+		{FinishReason: "stop"},
+		{Usage: gollum.CompletionUsage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}},
+	}
+
+	complete := Middleware(opt)(func(request gollum.CompletionRequest, stream gollum.StreamingFunction) (gollum.CompletionResponse, error) {
+		for _, chunk := range chunks {
+			if err := stream(chunk); err != nil {
+				return gollum.CompletionResponse{}, err
+			}
+		}
+		return gollum.CompletionResponse{}, nil
+	})
+
+	received := 0
+	_, err := complete(gollum.CompletionRequest{Model: "gpt-4o-mini"}, func(gollum.CompletionResponse) error {
+		received++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received != len(chunks) {
+		t.Errorf("received %d chunks, want %d", received, len(chunks))
+	}
+
+	span := onlySpan(t, recorder)
+	events := span.Events()
+	if len(events) != 1 || events[0].Name != "gen_ai.content.first_chunk" {
+		t.Errorf("events = %+v", events)
+	}
+	checkAttributes(t, span,
+		system.String("openai"),
+		responseID.String("chatcmpl-2"),
+		responseFinish.StringSlice([]string{"stop"}),
+		usageInputTokens.Int(5),
+		usageOutputTokens.Int(2),
+	)
+}
+
+func TestMiddlewareError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		errorType string
+	}{
+		{
+			name: "api error",
+			err: &gollum.APIError{
+				Provider:   "gemini",
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "RESOURCE_EXHAUSTED",
+				Message:    "quota exceeded",
+			},
+			errorType: "RESOURCE_EXHAUSTED",
+		},
+		{
+			name:      "status only",
+			err:       &gollum.APIError{Provider: "ollama", StatusCode: http.StatusBadGateway},
+			errorType: "502",
+		},
+		{
+			name:      "timeout",
+			err:       context.DeadlineExceeded,
+			errorType: "timeout",
+		},
+		{
+			name:      "other",
+			err:       errors.New("boom"),
+			errorType: "*errors.errorString",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, opt := newRecorder()
+			complete := Middleware(opt)(func(gollum.CompletionRequest, gollum.StreamingFunction) (gollum.CompletionResponse, error) {
+				return gollum.CompletionResponse{}, tt.err
+			})
+			_, err := complete(gollum.CompletionRequest{Model: "m"}, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			span := onlySpan(t, recorder)
+			if span.Status().Code != codes.Error || span.Status().Description != tt.err.Error() {
+				t.Errorf("status = %+v", span.Status())
+			}
+			checkAttributes(t, span, errorType.String(tt.errorType))
+			events := span.Events()
+			if len(events) != 1 || events[0].Name != "exception" {
+				t.Errorf("events = %+v", events)
+			}
+
+			var apiErr *gollum.APIError
+			if errors.As(tt.err, &apiErr) {
+				checkAttributes(t, span,
+					system.String(systems[apiErr.Provider]),
+					httpStatusCode.Int(apiErr.StatusCode),
+				)
+			}
+		})
+	}
+}
+
+func TestTraceTool(t *testing.T) {
+	recorder, opt := newRecorder()
+	call := m.ToolCall{Id: "call_1", Name: "weather", Arguments: []byte(`{"city":"Rome"}`)}
+
+	ctx, step := StartStep(context.Background(), "planner", opt)
+	var inner trace.SpanContext
+	output, err := TraceTool(ctx, call, func(ctx context.Context) (string, error) {
+		inner = trace.SpanContextFromContext(ctx)
+		return "sunny", nil
+	}, opt)
+	if err != nil || output != "sunny" {
+		t.Fatalf("output = %q, error = %v", output, err)
+	}
+	_, err = TraceTool(ctx, call, func(context.Context) (string, error) {
+		return "", errors.New("service unavailable")
+	}, opt)
+	if err == nil {
+		t.Fatal("expected the tool error")
+	}
+	step.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended spans = %d, want 3", len(spans))
+	}
+	ok, failed, parent := spans[0], spans[1], spans[2]
+	if parent.Name() != "invoke_agent planner" {
+		t.Errorf("step span = %q", parent.Name())
+	}
+	for _, span := range []sdktrace.ReadOnlySpan{ok, failed} {
+		if span.Name() != "execute_tool weather" || span.SpanKind() != trace.SpanKindInternal {
+			t.Errorf("tool span = %q, kind %s", span.Name(), span.SpanKind())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Error("the tool span is not a child of the step span")
+		}
+		checkAttributes(t, span,
+			operationName.String(operationTool),
+			toolName.String("weather"),
+			toolCallID.String("call_1"),
+		)
+	}
+	if inner.SpanID() != ok.SpanContext().SpanID() {
+		t.Error("the tool span is not passed to run")
+	}
+	if ok.Status().Code != codes.Unset {
+		t.Errorf("status = %+v", ok.Status())
+	}
+	if failed.Status().Code != codes.Error || failed.Status().Description != "service unavailable" {
+		t.Errorf("status = %+v", failed.Status())
+	}
+}
+
+func TestMiddlewareRedactsKey(t *testing.T) {
+	recorder, opt := newRecorder()
+	const key = "AIzaSyA-secret-key"
+	transportErr := &url.Error{
+		Op:  "Post",
+		URL: "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash:generateContent?alt=sse&key=" + key,
+		Err: errors.New("dial tcp: connection refused"),
+	}
+	complete := Middleware(opt)(func(gollum.CompletionRequest, gollum.StreamingFunction) (gollum.CompletionResponse, error) {
+		return gollum.CompletionResponse{}, transportErr
+	})
+	_, err := complete(gollum.CompletionRequest{Model: "gemini-1.5-flash"}, nil)
+	if !errors.Is(err, transportErr) {
+		t.Fatalf("error = %v", err)
+	}
+
+	span := onlySpan(t, recorder)
+	recorded := []string{span.Status().Description}
+	for _, event := range span.Events() {
+		for _, kv := range event.Attributes {
+			recorded = append(recorded, kv.Value.Emit())
+		}
+	}
+	for _, kv := range span.Attributes() {
+		recorded = append(recorded, kv.Value.Emit())
+	}
+	for _, value := range recorded {
+		if strings.Contains(value, key) {
+			t.Errorf("the API key is exported: %s", value)
+		}
+	}
+	if !strings.Contains(span.Status().Description, "key=[REDACTED]") {
+		t.Errorf("status = %q", span.Status().Description)
+	}
+	checkAttributes(t, span, errorType.String("*url.Error"))
+}
@@ -0,0 +1,374 @@
+package gollum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ModelPrice is the price of a model in dollars per million tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// defaultPrices are the public list prices at the time of writing. Use
+// PriceTable.Set or PriceTable.Load to override them.
+var defaultPrices = map[string]ModelPrice{
+	"gpt-4o":                      {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":                 {Input: 0.15, Output: 0.60},
+	"gpt-4.1":                     {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":                {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":                {Input: 0.10, Output: 0.40},
+	"gpt-4-turbo":                 {Input: 10.00, Output: 30.00},
+	"gpt-4":                       {Input: 30.00, Output: 60.00},
+	"gpt-3.5-turbo":               {Input: 0.50, Output: 1.50},
+	"o1":                          {Input: 15.00, Output: 60.00},
+	"o1-mini":                     {Input: 1.10, Output: 4.40},
+	"o3-mini":                     {Input: 1.10, Output: 4.40},
+	"gemini-1.5-flash":            {Input: 0.075, Output: 0.30},
+	"gemini-1.5-pro":              {Input: 1.25, Output: 5.00},
+	"gemini-2.0-flash":            {Input: 0.10, Output: 0.40},
+	"anthropic.claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"anthropic.claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"anthropic.claude-3-sonnet":   {Input: 3.00, Output: 15.00},
+	"anthropic.claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+	"anthropic.claude-3-opus":     {Input: 15.00, Output: 75.00},
+	"amazon.nova-micro":           {Input: 0.035, Output: 0.14},
+	"amazon.nova-lite":            {Input: 0.06, Output: 0.24},
+	"amazon.nova-pro":             {Input: 0.80, Output: 3.20},
+}
+
+// PriceTable maps model names to prices. Lookups fall back to the longest
+// known prefix, so that dated versions such as gpt-4o-2024-08-06 use the
+// price of gpt-4o.
+type PriceTable struct {
+	mu     sync.RWMutex
+	prices map[string]ModelPrice
+}
+
+// NewPriceTable returns a table filled with the default prices.
+func NewPriceTable() *PriceTable {
+	prices := make(map[string]ModelPrice, len(defaultPrices))
+	for model, price := range defaultPrices {
+		prices[model] = price
+	}
+	return &PriceTable{prices: prices}
+}
+
+// LoadPriceTable reads a JSON object mapping model names to prices from
+// path, on top of the default prices.
+func LoadPriceTable(path string) (*PriceTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table := NewPriceTable()
+	err = table.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Load reads a JSON object mapping model names to prices, overriding the
+// models already in the table.
+func (pt *PriceTable) Load(reader io.Reader) error {
+	prices := map[string]ModelPrice{}
+	err := json.NewDecoder(reader).Decode(&prices)
+	if err != nil {
+		return err
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	for model, price := range prices {
+		pt.prices[model] = price
+	}
+	return nil
+}
+
+func (pt *PriceTable) Set(model string, price ModelPrice) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.prices[model] = price
+}
+
+func (pt *PriceTable) Price(model string) (ModelPrice, bool) {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	if price, ok := pt.prices[model]; ok {
+		return price, true
+	}
+	best := ""
+	for known := range pt.prices {
+		if strings.HasPrefix(model, known) && len(known) > len(best) {
+			best = known
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return pt.prices[best], true
+}
+
+// CostOf returns the cost in dollars of usage on model. The boolean is
+// false when the model has no known price.
+func (pt *PriceTable) CostOf(model string, usage CompletionUsage) (float64, bool) {
+	price, ok := pt.Price(model)
+	if !ok {
+		return 0, false
+	}
+	cost := float64(usage.PromptTokens)*price.Input/1e6 + float64(usage.CompletionTokens)*price.Output/1e6
+	return cost, true
+}
+
+// Cost returns the cost in dollars of a completion response.
+func (pt *PriceTable) Cost(res CompletionResponse) (float64, bool) {
+	return pt.CostOf(res.Model, res.Usage)
+}
+
+// CostTracker accumulates the cost of the completions of one or more
+// clients. It is safe for concurrent use.
+type CostTracker struct {
+	prices *PriceTable
+
+	mu      sync.Mutex
+	total   float64
+	byModel map[string]float64
+	unknown map[string]int
+}
+
+// NewCostTracker creates a tracker using prices, or the default prices
+// when prices is nil.
+func NewCostTracker(prices *PriceTable) *CostTracker {
+	if prices == nil {
+		prices = NewPriceTable()
+	}
+	return &CostTracker{
+		prices:  prices,
+		byModel: map[string]float64{},
+		unknown: map[string]int{},
+	}
+}
+
+// Add records usage on model and returns its cost.
+func (ct *CostTracker) Add(model string, usage CompletionUsage) float64 {
+	cost, ok := ct.prices.CostOf(model, usage)
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if !ok {
+		ct.unknown[model]++
+		return 0
+	}
+	ct.total += cost
+	ct.byModel[model] += cost
+	return cost
+}
+
+func (ct *CostTracker) Total() float64 {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.total
+}
+
+func (ct *CostTracker) ByModel() map[string]float64 {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	byModel := make(map[string]float64, len(ct.byModel))
+	for model, cost := range ct.byModel {
+		byModel[model] = cost
+	}
+	return byModel
+}
+
+// UnpricedModels returns how many completions were made with models that
+// have no known price.
+func (ct *CostTracker) UnpricedModels() map[string]int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	unknown := make(map[string]int, len(ct.unknown))
+	for model, n := range ct.unknown {
+		unknown[model] = n
+	}
+	return unknown
+}
+
+// Budget is a spending limit in dollars. Once the spent amount reaches the
+// limit, new requests fail with ErrBudgetExceeded. A Budget can be shared
+// by several clients, or attached to a context with ContextWithBudget.
+//
+// The estimated cost of a request is reserved before it is sent and
+// settled with the actual cost once it is done, so that concurrent
+// requests sharing a budget cannot overshoot it together.
+type Budget struct {
+	mu       sync.Mutex
+	limit    float64
+	spent    float64
+	reserved float64
+}
+
+func NewBudget(limit float64) (*Budget, error) {
+	if limit <= 0 {
+		return nil, errors.New("budget must be greater than 0.")
+	}
+	return &Budget{limit: limit}, nil
+}
+
+func (b *Budget) Spend(cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += cost
+}
+
+func (b *Budget) Spent() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+func (b *Budget) Remaining() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.limit-b.spent, 0)
+}
+
+func (b *Budget) Exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent >= b.limit
+}
+
+// reserve sets estimated aside for a request, unless the budget is
+// exceeded or estimated does not fit in what is neither spent nor
+// reserved.
+func (b *Budget) reserve(estimated float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	committed := b.spent + b.reserved
+	if committed >= b.limit || committed+estimated > b.limit {
+		return false
+	}
+	b.reserved += estimated
+	return true
+}
+
+// settle replaces the reservation of a finished request with its cost.
+func (b *Budget) settle(estimated, cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserved = max(b.reserved-estimated, 0)
+	b.spent += cost
+}
+
+type budgetKey struct{}
+
+// ContextWithBudget attaches budget to ctx. Completions made with the
+// returned context, through WithContext, are charged to it in addition to
+// the client budget.
+func ContextWithBudget(ctx context.Context, budget *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+func budgetFromContext(ctx context.Context) *Budget {
+	if ctx == nil {
+		return nil
+	}
+	budget, _ := ctx.Value(budgetKey{}).(*Budget)
+	return budget
+}
+
+// Costs returns the cost tracker of the client, or nil.
+func (c LLMClient) Costs() *CostTracker {
+	return c.costs
+}
+
+// defaultPriceTable prices the budgets of clients without a cost tracker.
+var defaultPriceTable = sync.OnceValue(NewPriceTable)
+
+// estimateCost prices request with its estimated prompt tokens and its
+// completion budget, or returns 0 when the model has no known price.
+func estimateCost(prices *PriceTable, request CompletionRequest) float64 {
+	usage := CompletionUsage{}
+	if request.MaxCompletionTokens != nil {
+		usage.CompletionTokens = *request.MaxCompletionTokens
+		request.MaxCompletionTokens = nil
+	}
+	usage.PromptTokens = estimateTokens(request)
+	cost, _ := prices.CostOf(request.Model, usage)
+	return cost
+}
+
+func trackCost(tracker *CostTracker, clientBudget *Budget) Middleware {
+	return func(next CompleteFunc) CompleteFunc {
+		return func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+			prices := defaultPriceTable()
+			if tracker != nil {
+				prices = tracker.prices
+			}
+
+			budgets := []*Budget{}
+			estimated := 0.0
+			for _, b := range []*Budget{clientBudget, budgetFromContext(request.Ctx)} {
+				if b == nil {
+					continue
+				}
+				if len(budgets) == 0 {
+					estimated = estimateCost(prices, request)
+				}
+				if !b.reserve(estimated) {
+					for _, reserved := range budgets {
+						reserved.settle(estimated, 0)
+					}
+					return CompletionResponse{}, ErrBudgetExceeded
+				}
+				budgets = append(budgets, b)
+			}
+
+			usage := CompletionUsage{}
+			model := ""
+			if stream != nil {
+				streamFunction := stream
+				stream = func(res CompletionResponse) error {
+					if res.Usage.TotalTokens > 0 {
+						usage = res.Usage
+					}
+					if res.Model != "" {
+						model = res.Model
+					}
+					return streamFunction(res)
+				}
+			}
+
+			res, err := next(request, stream)
+			if stream == nil {
+				usage = res.Usage
+				model = res.Model
+			}
+			if model == "" {
+				model = request.Model
+			}
+			var cost float64
+			if usage.PromptTokens != 0 || usage.CompletionTokens != 0 {
+				if tracker != nil {
+					cost = tracker.Add(model, usage)
+				} else if len(budgets) > 0 {
+					cost, _ = prices.CostOf(model, usage)
+				}
+			}
+			for _, b := range budgets {
+				b.settle(estimated, cost)
+			}
+
+			return res, err
+		}
+	}
+}
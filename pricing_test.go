@@ -0,0 +1,91 @@
+package gollum
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Concurrent requests sharing a budget reserve their estimated cost before
+// they are sent, so that together they cannot overshoot it.
+func TestBudgetConcurrentRequests(t *testing.T) {
+	var served atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, `{"model":"m","message":{"role":"assistant","content":"ok"},"done":true,"prompt_eval_count":0,"eval_count":1}`)
+	}))
+	defer server.Close()
+
+	// a completion token costs $1, and every request may use one
+	prices := NewPriceTable()
+	prices.Set("m", ModelPrice{Input: 0, Output: 1e6})
+	budget, err := NewBudget(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(WithProvider(OLLAMA), WithAPIBase(server.URL), WithCostTracker(NewCostTracker(prices)), WithBudget(budget))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var succeeded, rejected atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := client.Complete(WithModel("m"), WithMessage("hi"), WithMaxCompletionTokens(1))
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, ErrBudgetExceeded):
+				rejected.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded.Load() != 5 || rejected.Load() != 15 || served.Load() != 5 {
+		t.Errorf("succeeded %d, rejected %d, served %d", succeeded.Load(), rejected.Load(), served.Load())
+	}
+	if spent := budget.Spent(); spent != 5 {
+		t.Errorf("spent = %v, want 5", spent)
+	}
+	if !budget.Exceeded() {
+		t.Error("the budget should be exhausted")
+	}
+}
+
+// A failed request gives its reservation back.
+func TestBudgetRefundsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error":"down"}`)
+	}))
+	defer server.Close()
+
+	prices := NewPriceTable()
+	prices.Set("m", ModelPrice{Input: 0, Output: 1e6})
+	budget, _ := NewBudget(1)
+	client, err := NewClient(WithProvider(OLLAMA), WithAPIBase(server.URL), WithCostTracker(NewCostTracker(prices)), WithBudget(budget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, _, err := client.Complete(WithModel("m"), WithMessage("hi"), WithMaxCompletionTokens(1))
+		if errors.Is(err, ErrBudgetExceeded) {
+			t.Fatalf("request %d: the reservation of a failed request was kept", i)
+		}
+	}
+	if spent := budget.Spent(); spent != 0 {
+		t.Errorf("spent = %v", spent)
+	}
+}
@@ -0,0 +1,104 @@
+// Package prometheus exports gollum completion metrics to a Prometheus
+// registry.
+//
+// It lives in its own module so that the core library does not depend on
+// the Prometheus client.
+package prometheus
+
+import (
+	"strconv"
+
+	"github.com/azr4e1/gollum"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gollum"
+
+// Collector implements gollum.MetricsCollector. Register it on a client
+// with gollum.WithMetrics.
+type Collector struct {
+	requests         *prom.CounterVec
+	errors           *prom.CounterVec
+	latency          *prom.HistogramVec
+	timeToFirstToken *prom.HistogramVec
+	promptTokens     *prom.CounterVec
+	completionTokens *prom.CounterVec
+}
+
+// NewCollector creates the metrics and registers them with reg. A nil reg
+// uses the default registerer.
+func NewCollector(reg prom.Registerer) (*Collector, error) {
+	if reg == nil {
+		reg = prom.DefaultRegisterer
+	}
+	labels := []string{"provider", "model"}
+	// completions take seconds rather than milliseconds
+	buckets := []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80}
+
+	c := &Collector{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of completion requests.",
+		}, labels),
+		errors: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "request_errors_total",
+			Help:      "Number of failed completion requests by error type.",
+		}, append(labels, "error_type", "status_code")),
+		latency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of completion requests.",
+			Buckets:   buckets,
+		}, append(labels, "stream")),
+		timeToFirstToken: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "time_to_first_token_seconds",
+			Help:      "Time until the first chunk of a streamed completion.",
+			Buckets:   buckets,
+		}, labels),
+		promptTokens: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "prompt_tokens_total",
+			Help:      "Number of prompt tokens.",
+		}, labels),
+		completionTokens: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "completion_tokens_total",
+			Help:      "Number of completion tokens.",
+		}, labels),
+	}
+
+	for _, collector := range []prom.Collector{c.requests, c.errors, c.latency, c.timeToFirstToken, c.promptTokens, c.completionTokens} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Collector) ObserveCompletion(m gollum.CompletionMetrics) {
+	c.requests.WithLabelValues(m.Provider, m.Model).Inc()
+
+	stream := "false"
+	if m.Stream {
+		stream = "true"
+	}
+	c.latency.WithLabelValues(m.Provider, m.Model, stream).Observe(m.Latency.Seconds())
+	if m.Stream && m.TimeToFirstToken > 0 {
+		c.timeToFirstToken.WithLabelValues(m.Provider, m.Model).Observe(m.TimeToFirstToken.Seconds())
+	}
+
+	if m.Err != nil {
+		status := ""
+		if m.StatusCode != 0 {
+			status = strconv.Itoa(m.StatusCode)
+		}
+		c.errors.WithLabelValues(m.Provider, m.Model, m.ErrorType, status).Inc()
+	}
+
+	c.promptTokens.WithLabelValues(m.Provider, m.Model).Add(float64(m.Usage.PromptTokens))
+	c.completionTokens.WithLabelValues(m.Provider, m.Model).Add(float64(m.Usage.CompletionTokens))
+}
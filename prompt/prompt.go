@@ -0,0 +1,329 @@
+// Package prompt builds chats from text/template templates.
+//
+// A prompt defines a "user" template and optionally a "system" template:
+//
+//	{{define "system"}}You are a {{.Role}}.{{end}}
+//	{{define "user"}}Translate to {{.Language}}: {{.Text}}{{end}}
+//
+// Without these definitions, the whole text is the user message. Other
+// definitions, from the same text or other files, can be used as partials
+// with {{template "name" .}}.
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	m "github.com/azr4e1/gollum/message"
+)
+
+const (
+	systemTemplate = "system"
+	userTemplate   = "user"
+)
+
+// Example is a few-shot example, sent as a user message and the expected
+// assistant answer between the system message and the user message.
+type Example struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// Prompt renders chats from templates with variables of type T, a struct
+// or a map with string keys. The variables used by the templates are
+// checked when parsing for structs, and when rendering for maps.
+type Prompt[T any] struct {
+	tmpl      *template.Template
+	user      string
+	examples  []Example
+	variables []string
+}
+
+// New parses a prompt from text.
+func New[T any](name, text string) (*Prompt[T], error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return newPrompt[T](tmpl)
+}
+
+// ParseFiles parses a prompt from files. The first file is the main
+// template, the others can hold partials.
+func ParseFiles[T any](filenames ...string) (*Prompt[T], error) {
+	if len(filenames) == 0 {
+		return nil, errors.New("no prompt files given.")
+	}
+	// the template named after the first file is the main one
+	tmpl, err := template.New(filepath.Base(filenames[0])).Option("missingkey=error").ParseFiles(filenames...)
+	if err != nil {
+		return nil, err
+	}
+	return newPrompt[T](tmpl)
+}
+
+// ParseFS parses a prompt from the files of fsys matching the patterns,
+// e.g. from an embed.FS.
+func ParseFS[T any](fsys fs.FS, patterns ...string) (*Prompt[T], error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no prompt files given.")
+	}
+	matches, err := fs.Glob(fsys, patterns[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("pattern matches no files: %s.", patterns[0])
+	}
+	tmpl, err := template.New(path.Base(matches[0])).Option("missingkey=error").ParseFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return newPrompt[T](tmpl)
+}
+
+func newPrompt[T any](tmpl *template.Template) (*Prompt[T], error) {
+	p := &Prompt[T]{tmpl: tmpl, user: userTemplate}
+	if tmpl.Lookup(userTemplate) == nil {
+		// the main template is the user message
+		if tmpl.Tree == nil {
+			return nil, errors.New("prompt has no user template.")
+		}
+		p.user = tmpl.Name()
+	}
+
+	variables := map[string]bool{}
+	for _, name := range []string{systemTemplate, p.user} {
+		if t := tmpl.Lookup(name); t != nil && t.Tree != nil {
+			collectVariables(tmpl, t.Tree.Root, variables, map[string]bool{})
+		}
+	}
+	for name := range variables {
+		p.variables = append(p.variables, name)
+	}
+	slices.Sort(p.variables)
+
+	err := checkStruct[T](p.variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// WithExamples returns a copy of the prompt with few-shot examples.
+func (p *Prompt[T]) WithExamples(examples ...Example) *Prompt[T] {
+	copied := *p
+	copied.examples = append(slices.Clone(p.examples), examples...)
+	return &copied
+}
+
+// Variables returns the names of the variables used by the templates.
+func (p *Prompt[T]) Variables() []string {
+	return slices.Clone(p.variables)
+}
+
+// Chat renders the prompt with vars into a chat ready for WithChat.
+func (p *Prompt[T]) Chat(vars T) (m.Chat, error) {
+	err := p.checkMap(vars)
+	if err != nil {
+		return m.Chat{}, err
+	}
+
+	chat := m.NewChat()
+	if p.tmpl.Lookup(systemTemplate) != nil {
+		system, err := p.render(systemTemplate, vars)
+		if err != nil {
+			return m.Chat{}, err
+		}
+		chat.SetSystemMessage(system)
+	}
+	for _, example := range p.examples {
+		chat.Add(m.UserMessage(example.User), m.AssistantMessage(example.Assistant))
+	}
+	user, err := p.render(p.user, vars)
+	if err != nil {
+		return m.Chat{}, err
+	}
+	chat.Add(m.UserMessage(user))
+
+	return chat, nil
+}
+
+func (p *Prompt[T]) render(name string, vars T) (string, error) {
+	text := strings.Builder{}
+	err := p.tmpl.ExecuteTemplate(&text, name, vars)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// checkMap reports all the variables missing from a map at once, rather
+// than the first one found by the template.
+func (p *Prompt[T]) checkMap(vars T) error {
+	value := reflect.ValueOf(vars)
+	if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	missing := []string{}
+	for _, name := range p.variables {
+		key := reflect.ValueOf(name).Convert(value.Type().Key())
+		if !value.MapIndex(key).IsValid() {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing prompt variables: %s.", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkStruct verifies that a struct T has every variable as a field or
+// method.
+func checkStruct[T any](variables []string) error {
+	typ := reflect.TypeFor[T]()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	ptr := reflect.PointerTo(typ)
+	missing := []string{}
+	for _, name := range variables {
+		if _, ok := typ.FieldByName(name); ok {
+			continue
+		}
+		if _, ok := ptr.MethodByName(name); ok {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s has no field for the prompt variables %s.", typ.String(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// collectVariables gathers the top level fields of the data used by node,
+// following the partials called with the same data.
+func collectVariables(tmpl *template.Template, node parse.Node, variables map[string]bool, visited map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectVariables(tmpl, child, variables, visited)
+		}
+	case *parse.ActionNode:
+		collectPipe(n.Pipe, variables)
+	case *parse.IfNode:
+		collectPipe(n.Pipe, variables)
+		collectVariables(tmpl, n.List, variables, visited)
+		collectVariables(tmpl, n.ElseList, variables, visited)
+	case *parse.RangeNode:
+		// dot changes inside the body, only the pipeline uses the data
+		collectPipe(n.Pipe, variables)
+		collectRoot(n.List, variables)
+		collectVariables(tmpl, n.ElseList, variables, visited)
+	case *parse.WithNode:
+		collectPipe(n.Pipe, variables)
+		collectRoot(n.List, variables)
+		collectVariables(tmpl, n.ElseList, variables, visited)
+	case *parse.TemplateNode:
+		if n.Pipe == nil {
+			return
+		}
+		collectPipe(n.Pipe, variables)
+		if len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 {
+			if _, ok := n.Pipe.Cmds[0].Args[0].(*parse.DotNode); ok && !visited[n.Name] {
+				visited[n.Name] = true
+				if t := tmpl.Lookup(n.Name); t != nil && t.Tree != nil {
+					collectVariables(tmpl, t.Tree.Root, variables, visited)
+				}
+			}
+		}
+	}
+}
+
+func collectPipe(pipe *parse.PipeNode, variables map[string]bool) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				variables[a.Ident[0]] = true
+			case *parse.ChainNode:
+				if _, ok := a.Node.(*parse.DotNode); ok && len(a.Field) > 0 {
+					variables[a.Field[0]] = true
+				}
+			case *parse.VariableNode:
+				if len(a.Ident) > 1 && a.Ident[0] == "$" {
+					variables[a.Ident[1]] = true
+				}
+			case *parse.PipeNode:
+				collectPipe(a, variables)
+			}
+		}
+	}
+}
+
+// collectRoot gathers the $.Field references of a body where dot is not
+// the data anymore.
+func collectRoot(node parse.Node, variables map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectRoot(child, variables)
+		}
+	case *parse.ActionNode:
+		collectRootPipe(n.Pipe, variables)
+	case *parse.IfNode:
+		collectRootPipe(n.Pipe, variables)
+		collectRoot(n.List, variables)
+		collectRoot(n.ElseList, variables)
+	case *parse.RangeNode:
+		collectRootPipe(n.Pipe, variables)
+		collectRoot(n.List, variables)
+		collectRoot(n.ElseList, variables)
+	case *parse.WithNode:
+		collectRootPipe(n.Pipe, variables)
+		collectRoot(n.List, variables)
+		collectRoot(n.ElseList, variables)
+	case *parse.TemplateNode:
+		collectRootPipe(n.Pipe, variables)
+	}
+}
+
+func collectRootPipe(pipe *parse.PipeNode, variables map[string]bool) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.VariableNode:
+				if len(a.Ident) > 1 && a.Ident[0] == "$" {
+					variables[a.Ident[1]] = true
+				}
+			case *parse.PipeNode:
+				collectRootPipe(a, variables)
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package gollum
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter throttles requests with token buckets for requests per minute
+// and tokens per minute, kept separately for each model. A limit of 0
+// disables that bucket. A RateLimiter can be shared by several clients.
+type RateLimiter struct {
+	// AutoTune adjusts the buckets from the x-ratelimit-limit-* and
+	// x-ratelimit-remaining-* response headers.
+	AutoTune bool
+
+	mu      sync.Mutex
+	rpm     int
+	tpm     int
+	buckets map[string]*modelBuckets
+}
+
+type modelBuckets struct {
+	requests *bucket
+	tokens   *bucket
+}
+
+type bucket struct {
+	capacity float64
+	level    float64
+	updated  time.Time
+}
+
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) (*RateLimiter, error) {
+	if requestsPerMinute < 0 || tokensPerMinute < 0 {
+		return nil, errors.New("rate limits cannot be negative.")
+	}
+	limiter := &RateLimiter{
+		rpm:     requestsPerMinute,
+		tpm:     tokensPerMinute,
+		buckets: map[string]*modelBuckets{},
+	}
+
+	return limiter, nil
+}
+
+func newBucket(capacity int, now time.Time) *bucket {
+	if capacity <= 0 {
+		return nil
+	}
+	return &bucket{capacity: float64(capacity), level: float64(capacity), updated: now}
+}
+
+// refill adds the capacity accrued since the last update. Buckets refill
+// their whole capacity over one minute.
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.level = min(b.capacity, b.level+b.capacity*elapsed.Minutes())
+	b.updated = now
+}
+
+// wait returns how long it takes until n units are available. Requests
+// larger than the capacity only wait for a full bucket.
+func (b *bucket) wait(n float64) time.Duration {
+	n = min(n, b.capacity)
+	if b.level >= n {
+		return 0
+	}
+	missing := (n - b.level) / b.capacity
+	return time.Duration(missing * float64(time.Minute))
+}
+
+func (rl *RateLimiter) model(model string, now time.Time) *modelBuckets {
+	mb, ok := rl.buckets[model]
+	if !ok {
+		mb = &modelBuckets{
+			requests: newBucket(rl.rpm, now),
+			tokens:   newBucket(rl.tpm, now),
+		}
+		rl.buckets[model] = mb
+	}
+	return mb
+}
+
+// Wait blocks until one request and the given number of tokens can be spent
+// for model, or until ctx is done.
+func (rl *RateLimiter) Wait(ctx context.Context, model string, tokens int) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for {
+		rl.mu.Lock()
+		now := time.Now()
+		mb := rl.model(model, now)
+		var delay time.Duration
+		if b := mb.requests; b != nil {
+			b.refill(now)
+			delay = max(delay, b.wait(1))
+		}
+		if b := mb.tokens; b != nil {
+			b.refill(now)
+			delay = max(delay, b.wait(float64(tokens)))
+		}
+		if delay == 0 {
+			if b := mb.requests; b != nil {
+				b.level--
+			}
+			if b := mb.tokens; b != nil {
+				b.level -= min(float64(tokens), b.capacity)
+			}
+			rl.mu.Unlock()
+			return nil
+		}
+		rl.mu.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return context.DeadlineExceeded
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Observe corrects the token bucket of model once the real usage of a
+// request is known, and applies the rate limit headers when AutoTune is
+// enabled.
+func (rl *RateLimiter) Observe(model string, estimated, used int, header http.Header) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	mb := rl.model(model, now)
+	if b := mb.tokens; b != nil && used > 0 {
+		b.refill(now)
+		b.level = min(b.capacity, b.level+float64(estimated-used))
+	}
+
+	if !rl.AutoTune || header == nil {
+		return
+	}
+	mb.requests = tuneBucket(mb.requests, header, "requests", now)
+	mb.tokens = tuneBucket(mb.tokens, header, "tokens", now)
+}
+
+// Refund gives the estimated tokens of a request back to the bucket of
+// model, when the request failed without consuming any.
+func (rl *RateLimiter) Refund(model string, estimated int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if b := rl.model(model, now).tokens; b != nil {
+		b.refill(now)
+		b.level = min(b.capacity, b.level+float64(estimated))
+	}
+}
+
+func tuneBucket(b *bucket, header http.Header, limit string, now time.Time) *bucket {
+	if capacity, ok := headerInt(header, "X-Ratelimit-Limit-"+limit); ok && capacity > 0 {
+		if b == nil {
+			b = newBucket(capacity, now)
+		}
+		b.refill(now)
+		b.capacity = float64(capacity)
+	}
+	if b == nil {
+		return nil
+	}
+	if remaining, ok := headerInt(header, "X-Ratelimit-Remaining-"+limit); ok {
+		b.refill(now)
+		b.level = min(b.level, float64(remaining))
+	}
+	return b
+}
+
+func headerInt(header http.Header, name string) (int, bool) {
+	value, err := strconv.Atoi(strings.TrimSpace(header.Get(name)))
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// estimateTokens approximates the tokens a request will consume from the
+// length of its messages, about four characters per token, plus the
+// completion budget when one is set.
+func estimateTokens(request CompletionRequest) int {
+	chars := len(request.System.Content)
+	tokens := 0
+	for _, mess := range request.Messages {
+		chars += len(mess.Content)
+		for _, tc := range mess.ToolCalls {
+			chars += len(tc.Name) + len(tc.Arguments)
+		}
+		// per message overhead of the chat formats
+		tokens += 4
+	}
+	tokens += chars / 4
+	if request.MaxCompletionTokens != nil {
+		tokens += *request.MaxCompletionTokens
+	}
+
+	return tokens
+}
+
+func rateLimit(limiter *RateLimiter) Middleware {
+	return func(next CompleteFunc) CompleteFunc {
+		return func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+			estimated := estimateTokens(request)
+			err := limiter.Wait(request.Ctx, request.Model, estimated)
+			if err != nil {
+				return CompletionResponse{}, err
+			}
+
+			var header http.Header
+			used := 0
+			if stream != nil {
+				streamFunction := stream
+				stream = func(res CompletionResponse) error {
+					header = res.Header
+					if res.Usage.TotalTokens > 0 {
+						used = res.Usage.TotalTokens
+					}
+					return streamFunction(res)
+				}
+			}
+
+			res, err := next(request, stream)
+			if stream == nil {
+				header = res.Header
+				used = res.Usage.TotalTokens
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				header = apiErr.Header
+			}
+			limiter.Observe(request.Model, estimated, used, header)
+			// a failure before the provider, e.g. a connection error or an
+			// open circuit, must not throttle the requests that follow
+			if err != nil && used == 0 {
+				limiter.Refund(request.Model, estimated)
+			}
+
+			return res, err
+		}
+	}
+}
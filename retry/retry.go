@@ -0,0 +1,169 @@
+// Package retry implements the retry policy shared by the provider clients.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes how failed HTTP requests are retried. The zero value
+// sends each request exactly once.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// MaxDelay caps the backoff between attempts. When the server asks to
+	// wait longer than MaxDelay, its reply is returned without retrying.
+	MaxDelay time.Duration
+	// Jitter is the fraction of each delay, between 0 and 1, that is
+	// randomized to spread out concurrent retries.
+	Jitter float64
+}
+
+var retryableStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// Do sends req with client, retrying connection failures and transient
+// status codes. Only the response status is inspected, so a response whose
+// body is being streamed is never retried.
+func (p Policy) Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		res, err := client.Do(req)
+		if !p.shouldRetry(attempt, req, res, err) {
+			return res, err
+		}
+
+		delay, ok := p.delay(attempt, res)
+		if !ok {
+			return res, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return res, err
+		}
+		if res != nil {
+			// drain so that the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p Policy) shouldRetry(attempt int, req *http.Request, res *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	// the body cannot be sent a second time
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		if req.Context().Err() != nil || errors.Is(err, context.Canceled) {
+			return false
+		}
+		return true
+	}
+
+	return retryableStatus[res.StatusCode]
+}
+
+// delay returns how long to wait before the next attempt. Delays requested
+// by the server take precedence over the exponential backoff, unless they
+// exceed MaxDelay, in which case it returns false and no retry is made.
+func (p Policy) delay(attempt int, res *http.Response) (time.Duration, bool) {
+	if res != nil {
+		if d, ok := ServerDelay(res.Header); ok {
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+
+	d := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+
+	return time.Duration(d), true
+}
+
+// ServerDelay reads the delay requested by the server from the Retry-After
+// headers, or from the rate limit reset headers of an exhausted limit.
+func ServerDelay(header http.Header) (time.Duration, bool) {
+	if ms := header.Get("Retry-After-Ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v >= 0 {
+			return time.Duration(v * float64(time.Millisecond)), true
+		}
+	}
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return max(time.Until(date), 0), true
+		}
+	}
+
+	var delay time.Duration
+	found := false
+	for _, limit := range []string{"requests", "tokens"} {
+		if strings.TrimSpace(header.Get("X-Ratelimit-Remaining-"+limit)) != "0" {
+			continue
+		}
+		if d, ok := ParseReset(header.Get("X-Ratelimit-Reset-" + limit)); ok && d > delay {
+			delay = d
+			found = true
+		}
+	}
+
+	return delay, found
+}
+
+// ParseReset parses a rate limit reset value, either a Go style duration
+// such as "6m0s" or "20ms", or a number of seconds.
+func ParseReset(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, true
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,258 @@
+package gollum
+
+import (
+	"cmp"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+type RouteStrategy int
+
+const (
+	// Fallback tries the backends in the order they were given.
+	Fallback RouteStrategy = iota
+	// Weighted picks the first backend at random, in proportion to the
+	// backend weights.
+	Weighted
+	// LowestLatency tries first the backend with the lowest average
+	// latency. Backends without measurements are tried before the others,
+	// and a failed request counts as taking the client timeout.
+	LowestLatency
+	// LowestCost tries first the backend whose model is the cheapest in
+	// the price table. Models without a price are tried last, so give local
+	// models a price of 0 to prefer them.
+	LowestCost
+)
+
+// defaultFallbackOn are the error classes, as returned by ErrorType, on
+// which the next backend is tried.
+var defaultFallbackOn = []string{"rate_limited", "server_error", "timeout", "network", "circuit_open"}
+
+// Backend is one of the clients of a Router.
+type Backend struct {
+	Client LLMClient
+	// Weight is used by the Weighted strategy.
+	Weight float64
+	// Models maps the requested model names to the ones of this backend,
+	// e.g. "gpt-4o-mini" to "llama3.1" for a local Ollama backend. Models
+	// missing from the map are requested unchanged.
+	Models map[string]string
+}
+
+func (b Backend) model(model string) string {
+	if mapped, ok := b.Models[model]; ok {
+		return mapped
+	}
+	return model
+}
+
+// Router sends completions to one of several clients, falling back to the
+// next one when a request fails with one of the FallbackOn error classes.
+// It is safe for concurrent use.
+type Router struct {
+	Strategy RouteStrategy
+	// FallbackOn lists the error classes, as returned by ErrorType, that
+	// move on to the next backend. It defaults to rate limits, server
+	// errors, timeouts, network errors and open circuits.
+	FallbackOn []string
+	// Prices are used by the LowestCost strategy. They default to the
+	// default price table.
+	Prices *PriceTable
+	// HedgeAfter sends a duplicate request to the second backend when the
+	// first has not answered after this delay, or has not sent its first
+	// chunk when streaming. The first to answer wins and the other is
+	// canceled. 0 disables hedging.
+	HedgeAfter time.Duration
+
+	backends       []Backend
+	stream         bool
+	streamFunction StreamingFunction
+
+	mu      sync.Mutex
+	latency []time.Duration
+}
+
+func NewRouter(strategy RouteStrategy, backends ...Backend) (*Router, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("router needs at least one backend.")
+	}
+	if strategy == Weighted {
+		total := 0.0
+		for _, b := range backends {
+			if b.Weight < 0 {
+				return nil, errors.New("backend weights cannot be negative.")
+			}
+			total += b.Weight
+		}
+		if total == 0 {
+			return nil, errors.New("at least one backend must have a weight.")
+		}
+	}
+
+	return &Router{
+		Strategy: strategy,
+		backends: backends,
+		latency:  make([]time.Duration, len(backends)),
+	}, nil
+}
+
+func (r *Router) EnableStream(function StreamingFunction) {
+	r.stream = true
+	r.streamFunction = function
+}
+
+func (r *Router) DisableStream() {
+	r.stream = false
+	r.streamFunction = nil
+}
+
+// Latency returns the average latency of every backend, 0 when unknown.
+func (r *Router) Latency() []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.latency)
+}
+
+// observe updates the moving average of the latency of backend i.
+func (r *Router) observe(i int, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.latency[i] == 0 {
+		r.latency[i] = latency
+		return
+	}
+	r.latency[i] = (4*r.latency[i] + latency) / 5
+}
+
+// failureLatency is the latency charged to a failed request: the timeout
+// of client, or the default timeout when it has none.
+func failureLatency(client LLMClient) time.Duration {
+	if client.Timeout > 0 {
+		return client.Timeout
+	}
+	return defaultTimeout
+}
+
+func (r *Router) shouldFallback(err error) bool {
+	fallbackOn := r.FallbackOn
+	if fallbackOn == nil {
+		fallbackOn = defaultFallbackOn
+	}
+	return slices.Contains(fallbackOn, ErrorType(err))
+}
+
+// order returns the indexes of the backends in the order they are tried.
+func (r *Router) order(model string) []int {
+	order := make([]int, len(r.backends))
+	for i := range order {
+		order[i] = i
+	}
+
+	switch r.Strategy {
+	case Weighted:
+		total := 0.0
+		for _, b := range r.backends {
+			total += b.Weight
+		}
+		pick := rand.Float64() * total
+		for i, b := range r.backends {
+			pick -= b.Weight
+			if pick < 0 && b.Weight > 0 {
+				order = append([]int{i}, slices.Delete(order, i, i+1)...)
+				break
+			}
+		}
+	case LowestLatency:
+		latency := r.Latency()
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(latency[a], latency[b])
+		})
+	case LowestCost:
+		prices := r.Prices
+		if prices == nil {
+			prices = defaultPriceTable()
+		}
+		cost := make([]float64, len(r.backends))
+		for i, b := range r.backends {
+			price, ok := prices.Price(b.model(model))
+			if !ok {
+				cost[i] = -1
+				continue
+			}
+			cost[i] = price.Input + price.Output
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			switch {
+			case cost[a] == cost[b]:
+				return 0
+			case cost[a] < 0:
+				return 1
+			case cost[b] < 0:
+				return -1
+			}
+			return cmp.Compare(cost[a], cost[b])
+		})
+	}
+
+	return order
+}
+
+// Complete sends the completion to the backends in the order of the
+// strategy, until one succeeds or fails with an error that is not in
+// FallbackOn. A streamed completion does not fall back once a chunk has
+// been delivered. The returned request is the one sent to the last backend
+// tried.
+func (r *Router) Complete(options ...completionOption) (CompletionRequest, CompletionResponse, error) {
+	request, err := NewCompletionRequest(options...)
+	if err != nil {
+		return *request, CompletionResponse{}, err
+	}
+	request.Stream = r.stream
+
+	var res CompletionResponse
+	sent := *request
+	order := r.order(request.Model)
+	if r.HedgeAfter > 0 && len(order) >= 2 {
+		var delivered bool
+		sent, res, err, delivered = r.hedge(request, order[0], order[1])
+		if err == nil || delivered || !r.shouldFallback(err) {
+			return sent, res, err
+		}
+		order = order[2:]
+	}
+	for _, i := range order {
+		backend := r.backends[i]
+		sent = *request
+		sent.Model = backend.model(request.Model)
+
+		delivered := false
+		var stream StreamingFunction
+		if r.stream {
+			stream = func(res CompletionResponse) error {
+				delivered = true
+				return r.streamFunction(res)
+			}
+		}
+
+		start := time.Now()
+		res, err = backend.Client.completeFunc()(sent, stream)
+		if err == nil {
+			r.observe(i, time.Since(start))
+			return sent, res, nil
+		}
+		// a failure is charged at least the client timeout, so that a
+		// backend failing fast is not taken for the fastest one
+		r.observe(i, max(time.Since(start), failureLatency(backend.Client)))
+		if delivered || !r.shouldFallback(err) {
+			return sent, res, err
+		}
+		if request.Ctx != nil && request.Ctx.Err() != nil {
+			return sent, res, err
+		}
+	}
+
+	return sent, res, err
+}
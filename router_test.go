@@ -0,0 +1,77 @@
+package gollum
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRouterFallback(t *testing.T) {
+	down := &fakeBackend{status: http.StatusServiceUnavailable}
+	local := &fakeBackend{content: "ok"}
+	router, err := NewRouter(Fallback,
+		Backend{Client: down.client(t)},
+		Backend{Client: local.client(t), Models: map[string]string{"gpt-4o-mini": "llama3.1"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, res, err := router.Complete(WithModel("gpt-4o-mini"), WithMessage("hi"))
+	if err != nil || res.Content() != "ok" {
+		t.Fatalf("response %q, error %v", res.Content(), err)
+	}
+	if sent.Model != "llama3.1" {
+		t.Errorf("sent model = %q, want the mapped one", sent.Model)
+	}
+	if down.calls.Load() != 1 || local.calls.Load() != 1 {
+		t.Errorf("calls = %d, %d", down.calls.Load(), local.calls.Load())
+	}
+}
+
+// An error outside FallbackOn is returned without trying the next backend.
+func TestRouterNoFallback(t *testing.T) {
+	rejecting := &fakeBackend{status: http.StatusBadRequest}
+	healthy := &fakeBackend{content: "ok"}
+	router := newTestRouter(t, Fallback, rejecting, healthy)
+
+	_, _, err := router.Complete(WithModel("m"), WithMessage("hi"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("error = %v, want the bad request", err)
+	}
+	if calls := healthy.calls.Load(); calls != 0 {
+		t.Errorf("the next backend was called %d times", calls)
+	}
+
+	router.FallbackOn = []string{"api_error"}
+	_, res, err := router.Complete(WithModel("m"), WithMessage("hi"))
+	if err != nil || res.Content() != "ok" {
+		t.Errorf("response %q, error %v", res.Content(), err)
+	}
+}
+
+// A backend failing fast is charged the client timeout, so that the
+// LowestLatency strategy stops trying it first.
+func TestRouterLowestLatencyChargesFailures(t *testing.T) {
+	failing := &fakeBackend{status: http.StatusInternalServerError}
+	healthy := &fakeBackend{content: "ok"}
+	router := newTestRouter(t, LowestLatency, failing, healthy)
+
+	for i := 0; i < 3; i++ {
+		_, res, err := router.Complete(WithModel("m"), WithMessage("hi"))
+		if err != nil || res.Content() != "ok" {
+			t.Fatalf("request %d: %q, %v", i, res.Content(), err)
+		}
+	}
+	latency := router.Latency()
+	if latency[0] < defaultTimeout || latency[1] <= 0 || latency[1] >= latency[0] {
+		t.Errorf("latencies = %v", latency)
+	}
+	if calls := failing.calls.Load(); calls != 1 {
+		t.Errorf("the failing backend was tried first %d times", calls)
+	}
+	if calls := healthy.calls.Load(); calls != 3 {
+		t.Errorf("the healthy backend was called %d times", calls)
+	}
+}
@@ -0,0 +1,212 @@
+package gollum
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// EmbeddingFunction returns the embedding vector of text.
+type EmbeddingFunction func(ctx context.Context, text string) ([]float64, error)
+
+// SemanticCache answers requests whose last user message is close enough,
+// by cosine similarity of their embeddings, to one already answered. Only
+// requests with the same provider, model and system message are compared.
+// The rest of the conversation is not considered, so it is best suited to
+// single turn questions. It is safe for concurrent use.
+type SemanticCache struct {
+	// TTL is how long an entry is reused. 0 keeps entries until they are
+	// evicted.
+	TTL time.Duration
+	// MaxEntries is the number of entries kept in each scope, the oldest
+	// are evicted first. 0 means no limit.
+	MaxEntries int
+
+	embed     EmbeddingFunction
+	threshold float64
+
+	mu     sync.RWMutex
+	scopes map[string][]semanticEntry
+}
+
+type semanticEntry struct {
+	vector []float64
+	entry  CacheEntry
+}
+
+// NewSemanticCache creates a cache that reuses a response when the
+// similarity of the embeddings is at least threshold, between 0 and 1.
+func NewSemanticCache(embed EmbeddingFunction, threshold float64) (*SemanticCache, error) {
+	if embed == nil {
+		return nil, errors.New("embedding function cannot be nil.")
+	}
+	if threshold <= 0 || threshold > 1 {
+		return nil, errors.New("similarity threshold must be between 0 and 1.")
+	}
+	return &SemanticCache{
+		embed:     embed,
+		threshold: threshold,
+		scopes:    map[string][]semanticEntry{},
+	}, nil
+}
+
+func semanticScope(provider string, request CompletionRequest) string {
+	return provider + "\x00" + request.Model + "\x00" + request.System.Content
+}
+
+// lastUserMessage returns the content of the last message when it comes
+// from the user.
+func lastUserMessage(request CompletionRequest) (string, bool) {
+	if len(request.Messages) == 0 {
+		return "", false
+	}
+	last := request.Messages[len(request.Messages)-1]
+	if last.Role != "user" || last.Content == "" {
+		return "", false
+	}
+	return last.Content, true
+}
+
+// normalize scales vector to unit length, so that cosine similarity is a
+// dot product.
+func normalize(vector []float64) []float64 {
+	norm := 0.0
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return nil
+	}
+	normalized := make([]float64, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
+
+func dot(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// lookup returns the entry most similar to vector in scope, if any is
+// above the threshold.
+func (sc *SemanticCache) lookup(scope string, vector []float64) (CacheEntry, bool) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	now := time.Now()
+	best := -1.0
+	var found CacheEntry
+	for _, se := range sc.scopes[scope] {
+		if se.entry.expired(now) {
+			continue
+		}
+		if similarity := dot(vector, se.vector); similarity >= sc.threshold && similarity > best {
+			best = similarity
+			found = se.entry
+		}
+	}
+	return found, best >= 0
+}
+
+func (sc *SemanticCache) add(scope string, vector []float64, entry CacheEntry) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := time.Now()
+	entries := sc.scopes[scope][:0]
+	for _, se := range sc.scopes[scope] {
+		if !se.entry.expired(now) {
+			entries = append(entries, se)
+		}
+	}
+	entries = append(entries, semanticEntry{vector: vector, entry: entry})
+	if sc.MaxEntries > 0 && len(entries) > sc.MaxEntries {
+		entries = append([]semanticEntry(nil), entries[len(entries)-sc.MaxEntries:]...)
+	}
+	sc.scopes[scope] = entries
+}
+
+// Len returns the number of entries in the cache.
+func (sc *SemanticCache) Len() int {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	n := 0
+	for _, entries := range sc.scopes {
+		n += len(entries)
+	}
+	return n
+}
+
+func (sc *SemanticCache) Clear() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.scopes = map[string][]semanticEntry{}
+}
+
+// middleware answers from the cache when possible. Embedding failures are
+// treated as misses.
+func (sc *SemanticCache) middleware(provider llmProvider) Middleware {
+	return func(next CompleteFunc) CompleteFunc {
+		return func(request CompletionRequest, stream StreamingFunction) (CompletionResponse, error) {
+			content, ok := lastUserMessage(request)
+			if !ok {
+				return next(request, stream)
+			}
+			ctx := request.Ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			embedding, err := sc.embed(ctx, content)
+			if err != nil {
+				return next(request, stream)
+			}
+			vector := normalize(embedding)
+			if vector == nil {
+				return next(request, stream)
+			}
+
+			scope := semanticScope(provider.String(), request)
+			if !request.CacheBypass {
+				if entry, ok := sc.lookup(scope, vector); ok {
+					return replayEntry(entry, stream)
+				}
+			}
+
+			var chunks []CompletionResponse
+			if stream != nil {
+				streamFunction := stream
+				stream = func(res CompletionResponse) error {
+					chunks = append(chunks, res)
+					return streamFunction(res)
+				}
+			}
+
+			res, err := next(request, stream)
+			if err != nil {
+				return res, err
+			}
+
+			entry := CacheEntry{Response: res, Chunks: chunks}
+			if stream != nil {
+				entry.Response = JoinChunks(chunks)
+			}
+			if sc.TTL > 0 {
+				entry.Expires = time.Now().Add(sc.TTL)
+			}
+			sc.add(scope, vector, entry)
+
+			return res, nil
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gollum
 
 import (
+	br "github.com/azr4e1/gollum/bedrock"
 	oai "github.com/azr4e1/gollum/openai"
 )
 
@@ -21,3 +22,7 @@ func (t Tool) ToOpenai() oai.OpenaiTool {
 
 	return oai.NewTool(name, description, args, required)
 }
+
+func (t Tool) ToBedrock() br.BedrockTool {
+	return br.NewTool(t.Function.Name, t.Function.Description, t.Function.Parameters)
+}
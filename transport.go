@@ -0,0 +1,45 @@
+package gollum
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultTransport is shared by every client that does not bring its own,
+// so that connections to the providers are kept alive and reused across
+// requests and clients.
+var defaultTransport http.RoundTripper = newDefaultTransport()
+
+func newDefaultTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 32
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.ForceAttemptHTTP2 = true
+
+	return transport
+}
+
+// httpClient returns the client passed down to the providers. A client set
+// with WithHTTPClient keeps its own timeout when it has one, and a
+// transport set with WithTransport replaces the client transport.
+func (c LLMClient) httpClient() *http.Client {
+	client := http.Client{Transport: defaultTransport, Timeout: c.Timeout}
+	if c.client != nil {
+		client = *c.client
+		if client.Timeout == 0 {
+			client.Timeout = c.Timeout
+		}
+		if client.Transport == nil {
+			client.Transport = defaultTransport
+		}
+	}
+	if c.transport != nil {
+		client.Transport = c.transport
+	}
+	if c.logger != nil && c.logBodies {
+		client.Transport = loggingTransport{requestLogger: c.requestLogger(), next: client.Transport}
+	}
+
+	return &client
+}
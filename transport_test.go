@@ -0,0 +1,52 @@
+package gollum_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/azr4e1/gollum"
+)
+
+// BenchmarkPooledTransport compares a new connection per request with
+// pooled connections, against a local TLS server speaking the Ollama chat
+// API, where the TLS handshake dominates the cost of a request.
+func BenchmarkPooledTransport(b *testing.B) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"gemma2:2b","message":{"role":"assistant","content":"pong"},"done":true}`)
+	}))
+	defer server.Close()
+	base := server.Client().Transport.(*http.Transport)
+
+	b.Run("fresh", func(b *testing.B) {
+		benchmarkTransport(b, server.URL, func() http.RoundTripper {
+			// a new transport per request cannot reuse any connection
+			transport := base.Clone()
+			transport.DisableKeepAlives = true
+			return transport
+		})
+	})
+
+	b.Run("shared", func(b *testing.B) {
+		shared := base.Clone()
+		defer shared.CloseIdleConnections()
+		benchmarkTransport(b, server.URL, func() http.RoundTripper {
+			return shared
+		})
+	})
+}
+
+func benchmarkTransport(b *testing.B, url string, transport func() http.RoundTripper) {
+	for i := 0; i < b.N; i++ {
+		client, err := gollum.NewClient(gollum.WithProvider(gollum.OLLAMA), gollum.WithAPIBase(url), gollum.WithTransport(transport()))
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _, err = client.Complete(gollum.WithModel("gemma2:2b"), gollum.WithMessage("ping"))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package gollum
 import (
 	"context"
 	"errors"
-	"fmt"
+	"net/http"
 )
 
 type TTSRequest struct {
@@ -16,22 +16,30 @@ type TTSRequest struct {
 }
 
 type TTSResponse struct {
-	Audio      []byte   `json:"audio"`
-	Error      TTSError `json:"error,omitempty"`
-	StatusCode int      `json:"status_code"`
+	Audio      []byte      `json:"audio"`
+	Error      TTSError    `json:"error,omitempty"`
+	StatusCode int         `json:"status_code"`
+	Provider   string      `json:"provider,omitempty"`
+	Header     http.Header `json:"-"`
+	// body is the raw error reply of the provider
+	body []byte
 }
 
 func (ttsr TTSResponse) Err() error {
-	if ttsr.Error.Type == "" && ttsr.Error.Message == "" {
+	if ttsr.Error.Type == "" && ttsr.Error.Message == "" && ttsr.StatusCode < http.StatusBadRequest {
 		return nil
 	}
-
-	return errors.New(fmt.Sprintf("%s: %s", ttsr.Error.Type, ttsr.Error.Message))
+	message := ttsr.Error.Message
+	if message == "" && ttsr.Error.Type == "" {
+		message = http.StatusText(ttsr.StatusCode)
+	}
+	return newAPIError(ttsr.Provider, ttsr.StatusCode, ttsr.Error.Code, ttsr.Error.Type, message, ttsr.Header, ttsr.body)
 }
 
 type TTSError struct {
 	Message string `json:"message"`
 	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
 }
 
 func NewTTSRequest(opts ...speechOption) (*TTSRequest, error) {
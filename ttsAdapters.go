@@ -23,12 +23,14 @@ func SpeechResponseFromOpenAI(response oai.TTSResponse) TTSResponse {
 		error = TTSError{
 			Message: response.Error.Message,
 			Type:    response.Error.Type,
+			Code:    response.Error.Code,
 		}
 	}
 	ttsResponse := TTSResponse{
 		Audio:      response.Audio,
 		Error:      error,
 		StatusCode: response.StatusCode,
+		Header:     response.Header,
 	}
 
 	return ttsResponse
@@ -42,7 +44,7 @@ func openaiTTS(request *TTSRequest, c LLMClient) (TTSRequest, TTSResponse, error
 	}
 	_, result, err := openaiClient.TextToSpeech(&openaiReq)
 	if err != nil {
-		return *request, TTSResponse{}, err
+		return *request, SpeechResponseFromOpenAI(result), errorFromOpenAI(err)
 	}
 
 	return *request, SpeechResponseFromOpenAI(result), nil